@@ -0,0 +1,37 @@
+package components
+
+import (
+	"github.com/knakk/rdf"
+)
+
+// buildCategoryPage fills in the page of a class, in the Category namespace,
+// from the triples of the class. The superclasses (rdfs:subClassOf) become
+// the categories of the page, as its parent categories, and the value of the
+// first description property found (in the most preferred language) becomes
+// its description, rather than a fact. The rdf:type marking the resource as
+// a class is left out, as it would put all categories into one. Other
+// triples, including superclasses that are blank nodes (such as OWL
+// restrictions), become facts, as for other pages.
+func (p *TripleAggregateToWikiPageConverter) buildCategoryPage(conv *pageConversion, aggr *TripleAggregate, resourceIndex ResourceIndex) {
+	page := conv.page
+	descProps := p.conf.CategoryPages.DescriptionProperties
+	page.Description = p.findPreferredValue(aggr.Triples, descProps)
+
+	for _, tr := range aggr.Triples {
+		pred := tr.Pred.String()
+		if pred == typePropertyURI && containsString(p.conf.CategoryTypes, tr.Obj.String()) {
+			continue
+		}
+		if containsString(descProps, pred) && tr.Obj.Type() == rdf.TermLiteral && tr.Obj.String() == page.Description {
+			continue
+		}
+		isParent := (pred == typePropertyURI || pred == subClassPropertyURI) && tr.Obj.Type() == rdf.TermIRI
+		for _, fact := range p.convertTriple(tr, conv, resourceIndex) {
+			if isParent {
+				page.AddCategoryUnique(NewCategory(fact.Value))
+			} else {
+				page.AddFactUnique(fact)
+			}
+		}
+	}
+}
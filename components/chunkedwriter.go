@@ -0,0 +1,180 @@
+package components
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/flowbase/flowbase"
+)
+
+// ChunkedMWXMLWriter is a process that writes a MediaWiki XML document, as
+// sent by MWXMLCreator on one of its ports, split into files of at most
+// MaxPages pages and at most MaxBytes bytes (uncompressed) each, where zero
+// means no limit. Each file is a complete document, and holds at least one
+// page, even if that page alone is larger than MaxBytes. The files are named
+// by ChunkFileName, numbered from 1, and no file is written if the document
+// has no pages.
+//
+// If a file can not be written, the error is reported as a *ConversionError
+// on the OutError port, and the remaining input is drained and discarded, so
+// that upstream processes can finish.
+type ChunkedMWXMLWriter struct {
+	In        chan string
+	OutDone   chan interface{}
+	OutError  chan *ConversionError
+	MaxPages  int
+	MaxBytes  int
+	fileName  string
+	fileNames []string
+}
+
+// NewChunkedMWXMLWriter returns an initialized ChunkedMWXMLWriter, writing
+// files named after fileName.
+func NewChunkedMWXMLWriter(fileName string, maxPages int, maxBytes int) *ChunkedMWXMLWriter {
+	return &ChunkedMWXMLWriter{
+		In:       make(chan string, BUFSIZE),
+		OutDone:  make(chan interface{}, BUFSIZE),
+		OutError: make(chan *ConversionError, BUFSIZE),
+		MaxPages: maxPages,
+		MaxBytes: maxBytes,
+		fileName: fileName,
+	}
+}
+
+// ChunkFileName returns the name of file number n of the files fileName is
+// split into, with the number added before the extension, as in
+// "pages_0001.xml.gz" for "pages.xml.gz".
+func ChunkFileName(fileName string, n int) string {
+	compressionExt := fileName[len(TrimCompressionExt(fileName)):]
+	baseName := TrimCompressionExt(fileName)
+	ext := filepath.Ext(baseName)
+	return fmt.Sprintf("%s_%04d%s%s", baseName[:len(baseName)-len(ext)], n, ext, compressionExt)
+}
+
+// FileNames returns the names of the files written, in order. It should only
+// be called after the process has finished.
+func (p *ChunkedMWXMLWriter) FileNames() []string {
+	return p.fileNames
+}
+
+func (p *ChunkedMWXMLWriter) Run() {
+	defer close(p.OutDone)
+	defer close(p.OutError)
+
+	if err := p.writeChunks(); err != nil {
+		p.OutError <- NewConversionError(p.fileName, 0, "", fmt.Errorf("could not write output file: %s", err.Error()))
+		for range p.In {
+		}
+	}
+
+	flowbase.Debug.Printf("Sending done signal on chan %v now in ChunkedMWXMLWriter ...\n", p.OutDone)
+	p.OutDone <- &DoneSignal{}
+}
+
+// writeChunks writes the pages received to files, starting each file with
+// the start of the document (the first string received), and ending it with
+// the end of the document.
+func (p *ChunkedMWXMLWriter) writeChunks() error {
+	header := ""
+	var chunk *xmlChunk
+	for s := range p.In {
+		if header == "" {
+			header = s
+			continue
+		}
+		if s == mwExportFooter {
+			continue
+		}
+		if chunk != nil && p.isFull(chunk, len(s)) {
+			if err := chunk.close(); err != nil {
+				return err
+			}
+			chunk = nil
+		}
+		if chunk == nil {
+			var err error
+			chunk, err = newXMLChunk(ChunkFileName(p.fileName, len(p.fileNames)+1))
+			if err != nil {
+				return err
+			}
+			p.fileNames = append(p.fileNames, chunk.fileName)
+			if err := chunk.write(header); err != nil {
+				chunk.abort()
+				return err
+			}
+		}
+		if err := chunk.write(s); err != nil {
+			chunk.abort()
+			return err
+		}
+		chunk.pages++
+	}
+	if chunk != nil {
+		return chunk.close()
+	}
+	return nil
+}
+
+// isFull tells whether a page of pageLen bytes has to go into the next file,
+// rather than into chunk.
+func (p *ChunkedMWXMLWriter) isFull(chunk *xmlChunk, pageLen int) bool {
+	if p.MaxPages > 0 && chunk.pages >= p.MaxPages {
+		return true
+	}
+	return p.MaxBytes > 0 && chunk.bytes+pageLen+len(mwExportFooter) > p.MaxBytes
+}
+
+// xmlChunk is one of the files written by ChunkedMWXMLWriter.
+type xmlChunk struct {
+	fileName string
+	fh       *os.File
+	bufFh    *bufio.Writer
+	w        io.WriteCloser
+	pages    int
+	bytes    int
+}
+
+func newXMLChunk(fileName string) (*xmlChunk, error) {
+	fh, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	bufFh := bufio.NewWriter(fh)
+	w, err := newCompressingWriter(bufFh, DetectCompression(fileName))
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	return &xmlChunk{fileName: fileName, fh: fh, bufFh: bufFh, w: w}, nil
+}
+
+func (c *xmlChunk) write(s string) error {
+	c.bytes += len(s)
+	_, err := c.w.Write([]byte(s))
+	return err
+}
+
+// close ends the document in the file, and closes it.
+func (c *xmlChunk) close() error {
+	if err := c.write(mwExportFooter); err != nil {
+		c.abort()
+		return err
+	}
+	if err := c.w.Close(); err != nil {
+		c.abort()
+		return err
+	}
+	if err := c.bufFh.Flush(); err != nil {
+		c.abort()
+		return err
+	}
+	return c.fh.Close()
+}
+
+// abort closes the file after an error.
+func (c *xmlChunk) abort() {
+	c.fh.Close()
+}
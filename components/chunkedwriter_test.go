@@ -0,0 +1,129 @@
+package components
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+)
+
+// TestChunkFileName tests that the chunk number goes before the extension,
+// and before the compression extension
+func TestChunkFileName(t *testing.T) {
+	tests := map[string]string{
+		"pages.xml":              "pages_0001.xml",
+		"pages.xml.gz":           "pages_0001.xml.gz",
+		"out/pages_templates.xz": "out/pages_templates_0001.xz",
+		"pages":                  "pages_0001",
+	}
+	for fileName, expected := range tests {
+		if chunkFileName := ChunkFileName(fileName, 1); chunkFileName != expected {
+			t.Errorf("Wrong chunk file name for %s (Expected %s, got %s)", fileName, expected, chunkFileName)
+		}
+	}
+}
+
+// TestChunkedMWXMLWriter tests that documents are split by the number of
+// pages and by size, into files that are each a complete document
+func TestChunkedMWXMLWriter(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	pages := []string{}
+	for i := 1; i <= 5; i++ {
+		pages = append(pages, marshalMWElement(newMWPage(i, fmt.Sprintf("Page %d", i), 0, "2024-01-31T12:00:00Z", "", "")))
+	}
+	pageLen := len(pages[0])
+	headerLen := len(mwExportHeader)
+
+	tests := []struct {
+		maxPages      int
+		maxBytes      int
+		expectedPages []int
+	}{
+		{0, 0, []int{5}},
+		{2, 0, []int{2, 2, 1}},
+		{0, headerLen + 3*pageLen + len(mwExportFooter), []int{3, 2}},
+		{0, 1, []int{1, 1, 1, 1, 1}},
+		{2, headerLen + 3*pageLen + len(mwExportFooter), []int{2, 2, 1}},
+	}
+	for _, tt := range tests {
+		fileName := filepath.Join(t.TempDir(), "pages.xml.gz")
+		writer := NewChunkedMWXMLWriter(fileName, tt.maxPages, tt.maxBytes)
+		go func() {
+			defer close(writer.In)
+			writer.In <- mwExportHeader
+			for _, page := range pages {
+				writer.In <- page
+			}
+			writer.In <- mwExportFooter
+		}()
+		go writer.Run()
+		for err := range writer.OutError {
+			t.Errorf("Could not write chunks: %s", err.Error())
+		}
+		<-writer.OutDone
+
+		fileNames := writer.FileNames()
+		if len(fileNames) != len(tt.expectedPages) {
+			t.Errorf("Wrong number of files with max %d pages and %d bytes (Expected %d, got %d)", tt.maxPages, tt.maxBytes, len(tt.expectedPages), len(fileNames))
+			continue
+		}
+		for i, chunkFileName := range fileNames {
+			if chunkFileName != ChunkFileName(fileName, i+1) {
+				t.Errorf("Wrong name of file %d: %s", i+1, chunkFileName)
+			}
+			dump := readDumpFile(t, chunkFileName)
+			if len(dump.Pages) != tt.expectedPages[i] {
+				t.Errorf("Wrong number of pages in file %d with max %d pages and %d bytes (Expected %d, got %d)", i+1, tt.maxPages, tt.maxBytes, tt.expectedPages[i], len(dump.Pages))
+			}
+		}
+	}
+}
+
+// TestChunkedMWXMLWriterEmpty tests that no file is written for a document
+// without pages
+func TestChunkedMWXMLWriterEmpty(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fileName := filepath.Join(t.TempDir(), "pages.xml")
+	writer := NewChunkedMWXMLWriter(fileName, 2, 0)
+	go func() {
+		defer close(writer.In)
+		writer.In <- mwExportHeader
+		writer.In <- mwExportFooter
+	}()
+	go writer.Run()
+	for err := range writer.OutError {
+		t.Errorf("Could not write chunks: %s", err.Error())
+	}
+	<-writer.OutDone
+
+	if len(writer.FileNames()) != 0 {
+		t.Errorf("Files written for empty document: %v", writer.FileNames())
+	}
+	if _, err := os.Stat(ChunkFileName(fileName, 1)); !os.IsNotExist(err) {
+		t.Error("File written for empty document")
+	}
+}
+
+// readDumpFile reads the (possibly compressed) export document in the file
+// fileName.
+func readDumpFile(t *testing.T, fileName string) *importedDump {
+	fh, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	r, err := newDecompressingReader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := &importedDump{}
+	if err := xml.NewDecoder(r).Decode(dump); err != nil {
+		t.Fatalf("File %s is not a well-formed document: %s", fileName, err.Error())
+	}
+	return dump
+}
@@ -0,0 +1,107 @@
+package components
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	str "strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Names of the compression formats understood by the readers and writers.
+const (
+	CompressionNone  = ""
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionXz    = "xz"
+)
+
+var compressionExtensions = map[string]string{
+	".gz":  CompressionGzip,
+	".bz2": CompressionBzip2,
+	".xz":  CompressionXz,
+}
+
+var compressionMagicBytes = map[string][]byte{
+	CompressionGzip:  {0x1f, 0x8b},
+	CompressionBzip2: []byte("BZh"),
+	CompressionXz:    {0xfd, '7', 'z', 'X', 'Z', 0x00},
+}
+
+// DetectCompression returns the compression format of a file, based on its
+// file extension, or CompressionNone if it does not have a known compression
+// file extension.
+func DetectCompression(fileName string) string {
+	return compressionExtensions[str.ToLower(filepath.Ext(fileName))]
+}
+
+// TrimCompressionExt returns fileName with any compression file extension
+// removed, such that "data.nt.gz" becomes "data.nt".
+func TrimCompressionExt(fileName string) string {
+	if DetectCompression(fileName) != CompressionNone {
+		return str.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	return fileName
+}
+
+// newDecompressingReader returns a reader returning the uncompressed content
+// of r. The compression format is detected from the magic bytes at the start
+// of the content, so that wrongly named files are still read correctly.
+// Uncompressed content is returned as is.
+func newDecompressingReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	compression := CompressionNone
+	for comprName, magic := range compressionMagicBytes {
+		head, _ := br.Peek(len(magic))
+		if bytes.Equal(head, magic) {
+			compression = comprName
+			break
+		}
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(br)
+	case CompressionBzip2:
+		return bzip2.NewReader(br), nil
+	case CompressionXz:
+		return xz.NewReader(br)
+	}
+	return br, nil
+}
+
+// newCompressingWriter returns a writer compressing everything written to it
+// into w, in the compression format compression. The returned writer has to
+// be closed to flush the compressed data, which does not close w.
+func newCompressingWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionXz:
+		return xz.NewWriter(w)
+	}
+	return nil, fmt.Errorf("writing %s compressed files is not supported", compression)
+}
+
+// CheckWritableCompression returns an error if files named like fileName can
+// not be written, due to an unsupported compression format.
+func CheckWritableCompression(fileName string) error {
+	w, err := newCompressingWriter(io.Discard, DetectCompression(fileName))
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
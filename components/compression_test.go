@@ -0,0 +1,109 @@
+package components
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+	"github.com/spf13/afero"
+	"github.com/ulikunitz/xz"
+)
+
+// TestDecompressingReader tests that gzip, xz and uncompressed content is
+// detected by magic bytes and read correctly
+func TestDecompressingReader(t *testing.T) {
+	content := "<http://example.org/s1> <http://example.org/p1> \"o1\" .\n"
+
+	gzBuf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(gzBuf)
+	gzw.Write([]byte(content))
+	gzw.Close()
+
+	xzBuf := &bytes.Buffer{}
+	xzw, err := xz.NewWriter(xzBuf)
+	if err != nil {
+		t.Fatal("Could not create xz writer: ", err.Error())
+	}
+	xzw.Write([]byte(content))
+	xzw.Close()
+
+	inputs := map[string][]byte{
+		CompressionNone: []byte(content),
+		CompressionGzip: gzBuf.Bytes(),
+		CompressionXz:   xzBuf.Bytes(),
+	}
+	for compression, input := range inputs {
+		r, err := newDecompressingReader(bytes.NewReader(input))
+		if err != nil {
+			t.Errorf("Could not create reader for %s content: %s", compression, err.Error())
+			continue
+		}
+		output, err := io.ReadAll(r)
+		if err != nil {
+			t.Errorf("Could not read %s content: %s", compression, err.Error())
+		}
+		if string(output) != content {
+			t.Errorf("Wrong output for %s content: %s", compression, string(output))
+		}
+	}
+}
+
+// TestCompressingWriter tests that written content can be read back
+func TestCompressingWriter(t *testing.T) {
+	content := "<mediawiki>\n</mediawiki>\n"
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionXz} {
+		buf := &bytes.Buffer{}
+		w, err := newCompressingWriter(buf, compression)
+		if err != nil {
+			t.Errorf("Could not create %s writer: %s", compression, err.Error())
+			continue
+		}
+		w.Write([]byte(content))
+		w.Close()
+
+		r, err := newDecompressingReader(buf)
+		if err != nil {
+			t.Errorf("Could not create reader for %s content: %s", compression, err.Error())
+			continue
+		}
+		output, _ := io.ReadAll(r)
+		if string(output) != content {
+			t.Errorf("Wrong content read back for %s: %s", compression, string(output))
+		}
+	}
+	if err := CheckWritableCompression("pages.xml.bz2"); err == nil {
+		t.Error("No error for unsupported bzip2 output")
+	}
+}
+
+// Tests that the RDFFileReader reads gzipped files, detecting the format
+// from the extension before the compression extension
+func TestRDFFileReaderGzip(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	gzw.Write([]byte(`<http://example.org/s1> <http://example.org/p1> "o1" .`))
+	gzw.Close()
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "data.nt.gz", buf.Bytes(), 0644)
+
+	if format := DetectRDFFormat("data.nt.gz"); format != RDFFormatNTriples {
+		t.Errorf("Wrong format detected for data.nt.gz: %s", format)
+	}
+
+	fr := NewRDFFileReader(fs)
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- "data.nt.gz"
+	}()
+	go fr.Run()
+
+	outTriple := <-fr.OutTriple
+	if outTriple.Obj.String() != "o1" {
+		t.Errorf("Wrong object read from gzipped file: %s", outTriple.Obj.String())
+	}
+}
@@ -0,0 +1,457 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	str "strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------------------------------------------------------------------------------
+// Config
+// --------------------------------------------------------------------------------
+
+// Config holds the mapping configuration, deciding how RDF resources are
+// converted into wiki pages, properties, categories and templates.
+type Config struct {
+	// TitleProperties lists the predicates used to find a title for a
+	// resource, in order of priority.
+	TitleProperties []string `json:"titleProperties"`
+	// Languages lists the preferred language tags, in order of preference,
+	// for picking a title among language-tagged title values.
+	Languages []string `json:"languages"`
+	// LanguageMode decides how language-tagged literals are written: "text",
+	// "monolingual" or "properties" (see LanguageModeText etc).
+	LanguageMode string `json:"languageMode"`
+	// NamespaceAbbreviations maps namespace URIs to short prefixes, taking
+	// priority over prefixes declared in the input and common prefixes.
+	NamespaceAbbreviations map[string]string `json:"namespaceAbbreviations"`
+	// AbbreviationPolicy decides which titles are made from an abbreviated
+	// namespace and a local name: "none", "properties", "all" or "collision"
+	// (see AbbreviateNone etc).
+	AbbreviationPolicy string `json:"abbreviationPolicy"`
+	// AbbreviationSeparator goes between the prefix and the local name, as
+	// in "dc:title" or "foaf name".
+	AbbreviationSeparator string `json:"abbreviationSeparator"`
+	// TitleCollisionStrategy decides how resources that would get the same
+	// title are given unique titles: "none", "namespace", "class" or "hash"
+	// (see TitleCollisionNone etc).
+	TitleCollisionStrategy string `json:"titleCollisionStrategy"`
+	// CapitalLinks makes the first letter of titles upper case, and should
+	// match $wgCapitalLinks of the wiki.
+	CapitalLinks bool `json:"capitalLinks"`
+	// ReservedTitlePrefixes lists namespace names and interwiki prefixes of
+	// the wiki, in addition to the standard ones, that titles must not start
+	// with (followed by a colon).
+	ReservedTitlePrefixes []string `json:"reservedTitlePrefixes"`
+	// PropertyTypes lists the rdf:type URIs marking a resource as a property.
+	PropertyTypes []string `json:"propertyTypes"`
+	// CategoryTypes lists the rdf:type URIs marking a resource as a class
+	// (category).
+	CategoryTypes []string `json:"categoryTypes"`
+	// DataTypes maps literal datatype URIs to SMW types ("Has type").
+	DataTypes map[string]string `json:"dataTypes"`
+	// ValueEscaping maps SMW types to the strategy for escaping values of the
+	// type in wikitext: "entities" (the default, for types not listed),
+	// "nowiki" or "substitute".
+	ValueEscaping map[string]string `json:"valueEscaping"`
+	// TypeConflictPolicy decides the SMW type of properties with values of
+	// several types: "majority" or "widest" (see TypePolicyMajority and
+	// TypePolicyWidest). A type given by rdfs:range always wins.
+	TypeConflictPolicy string `json:"typeConflictPolicy"`
+	// Templates holds options for how template calls are generated.
+	Templates TemplateConfig `json:"templates"`
+	// Redirects holds options for generating redirect pages.
+	Redirects RedirectConfig `json:"redirects"`
+	// CategoryPages holds options for the pages of classes.
+	CategoryPages CategoryPageConfig `json:"categoryPages"`
+	// SiteInfo describes the wiki to import into, in the siteinfo element of
+	// the generated XML.
+	SiteInfo SiteInfoConfig `json:"siteInfo"`
+}
+
+// TemplateConfig holds the options for generating templates and template
+// calls.
+type TemplateConfig struct {
+	// Enabled decides whether facts are written via template calls, for pages
+	// which have at least one category.
+	Enabled bool `json:"enabled"`
+	// CategoriesParam is the name of the template parameter holding the
+	// categories of a page.
+	CategoriesParam string `json:"categoriesParam"`
+	// ValueSeparator separates multiple values of the same template parameter.
+	ValueSeparator string `json:"valueSeparator"`
+}
+
+// RedirectConfig holds the options for generating redirect pages, from
+// alternative titles of resources to their pages.
+type RedirectConfig struct {
+	// Enabled decides whether redirect pages are generated, for the values
+	// of Properties, the values of the title properties not used for the
+	// title, and the titles made from the URIs of resources titled by a title
+	// property.
+	Enabled bool `json:"enabled"`
+	// Properties lists the predicates giving alternative titles, either as
+	// literal values, or as URIs of equivalent resources.
+	Properties []string `json:"properties"`
+	// DisambiguationPages decides whether alternative titles of several
+	// pages (such as the title shared by resources whose titles collided)
+	// get a page listing the pages, rather than no page.
+	DisambiguationPages bool `json:"disambiguationPages"`
+}
+
+// CategoryPageConfig holds the options for the pages of classes, in the
+// Category namespace.
+type CategoryPageConfig struct {
+	// Enabled decides whether the pages of classes get a description, a
+	// listing of the members of the category, and links to its template and
+	// form, with only the superclasses (rdfs:subClassOf) as categories.
+	Enabled bool `json:"enabled"`
+	// DescriptionProperties lists the predicates giving the description of a
+	// class, in order of priority.
+	DescriptionProperties []string `json:"descriptionProperties"`
+	// FormNamespace is the namespace of the forms (of the Page Forms
+	// extension) linked to, or empty for no link to a form.
+	FormNamespace string `json:"formNamespace"`
+}
+
+// SiteInfoConfig describes the wiki the generated XML is to be imported into.
+// The case rules of titles are taken from Config.CapitalLinks.
+type SiteInfoConfig struct {
+	// Sitename is the name of the wiki (optional).
+	Sitename string `json:"sitename"`
+	// DBName is the database name of the wiki (optional).
+	DBName string `json:"dbname"`
+	// Base is the URL of the main page of the wiki (optional).
+	Base string `json:"base"`
+	// Namespaces lists the namespaces of the wiki. It must include the
+	// namespaces pages are written to: the main namespace (0), Template (10),
+	// Category (14) and Property (102).
+	Namespaces []NamespaceConfig `json:"namespaces"`
+}
+
+// NamespaceConfig is a namespace of the wiki, with its number and name.
+type NamespaceConfig struct {
+	Key  int    `json:"key"`
+	Name string `json:"name"`
+}
+
+// smwTypes lists the SMW datatypes that can be used in the "Has type" property.
+var smwTypes = []string{
+	"Annotation URI",
+	"Boolean",
+	"Code",
+	"Date",
+	"Email",
+	"External identifier",
+	"Geographic coordinate",
+	"Keyword",
+	"Monolingual text",
+	"Number",
+	"Page",
+	"Quantity",
+	"Record",
+	"Reference",
+	"Telephone",
+	"Temperature",
+	"Text",
+	"URL",
+}
+
+// DefaultConfig returns the built-in mapping configuration, used when no
+// config file is given.
+func DefaultConfig() *Config {
+	return &Config{
+		TitleProperties: []string{
+			"http://semantic-mediawiki.org/swivt/1.0#page",
+			"http://www.w3.org/2000/01/rdf-schema#label",
+			"http://purl.org/dc/elements/1.1/title",
+			"http://purl.org/dc/terms/title",
+			"http://www.w3.org/2004/02/skos/core#preferredLabel",
+			"http://xmlns.com/foaf/0.1/name",
+		},
+		Languages:    []string{"en"},
+		LanguageMode: LanguageModeText,
+		NamespaceAbbreviations: map[string]string{
+			"http://www.opentox.org/api/1.1#": "opentox",
+		},
+		AbbreviationPolicy:     AbbreviateNone,
+		AbbreviationSeparator:  ":",
+		TitleCollisionStrategy: TitleCollisionNamespace,
+		CapitalLinks:           true,
+		PropertyTypes: []string{
+			"http://www.w3.org/1999/02/22-rdf-syntax-ns#Property",
+			"http://www.w3.org/2002/07/owl#AnnotationProperty",
+			"http://www.w3.org/2002/07/owl#DatatypeProperty",
+			"http://www.w3.org/2002/07/owl#ObjectProperty",
+		},
+		CategoryTypes: []string{
+			"http://www.w3.org/2002/07/owl#Class",
+		},
+		DataTypes: defaultDataTypes(),
+		ValueEscaping: map[string]string{
+			smwTypePage: ValueEscapingSubstitute,
+		},
+		TypeConflictPolicy: TypePolicyMajority,
+		Templates: TemplateConfig{
+			Enabled:         true,
+			CategoriesParam: "Categories",
+			ValueSeparator:  ",",
+		},
+		Redirects: RedirectConfig{
+			Enabled: true,
+			Properties: []string{
+				"http://www.w3.org/2004/02/skos/core#altLabel",
+				"http://www.w3.org/2002/07/owl#sameAs",
+			},
+			DisambiguationPages: true,
+		},
+		CategoryPages: CategoryPageConfig{
+			Enabled: true,
+			DescriptionProperties: []string{
+				"http://www.w3.org/2000/01/rdf-schema#comment",
+				"http://www.w3.org/2004/02/skos/core#definition",
+			},
+			FormNamespace: "Form",
+		},
+		SiteInfo: SiteInfoConfig{
+			Namespaces: defaultNamespaces(),
+		},
+	}
+}
+
+// defaultNamespaces returns the standard namespaces of MediaWiki, and the
+// namespaces of Semantic MediaWiki properties.
+func defaultNamespaces() []NamespaceConfig {
+	return []NamespaceConfig{
+		{-2, "Media"},
+		{-1, "Special"},
+		{0, ""},
+		{1, "Talk"},
+		{2, "User"},
+		{3, "User talk"},
+		{4, "Project"},
+		{5, "Project talk"},
+		{6, "File"},
+		{7, "File talk"},
+		{8, "MediaWiki"},
+		{9, "MediaWiki talk"},
+		{10, "Template"},
+		{11, "Template talk"},
+		{12, "Help"},
+		{13, "Help talk"},
+		{14, "Category"},
+		{15, "Category talk"},
+		{102, "Property"},
+		{103, "Property talk"},
+	}
+}
+
+// LoadConfig reads a mapping configuration from the file fileName, in YAML
+// format if the file name ends in .yaml or .yml, and otherwise in JSON format.
+// See ParseConfig for details.
+func LoadConfig(fileName string) (*Config, error) {
+	fh, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	parse := ParseConfig
+	if ext := str.ToLower(filepath.Ext(fileName)); ext == ".yaml" || ext == ".yml" {
+		parse = ParseYAMLConfig
+	}
+	conf, err := parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %s", fileName, err.Error())
+	}
+	return conf, nil
+}
+
+// ParseYAMLConfig reads a YAML mapping configuration from r, and validates
+// it. It has the same keys as the JSON configuration, and is read in the same
+// way as by ParseConfig, once converted to JSON.
+func ParseYAMLConfig(r io.Reader) (*Config, error) {
+	var doc interface{}
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if doc == nil {
+		// An empty file leaves all the defaults
+		doc = map[string]interface{}{}
+	}
+	configJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(bytes.NewReader(configJSON))
+}
+
+// ParseConfig reads a JSON mapping configuration from r, and validates it.
+// Keys not present in the JSON keep their values from DefaultConfig. List
+// values replace the defaults, while the entries of map values (such as
+// dataTypes) are merged into the defaults, replacing those with the same key.
+// Default map entries can thus be changed, but not removed.
+func ParseConfig(r io.Reader) (*Config, error) {
+	conf := DefaultConfig()
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(conf); err != nil {
+		return nil, err
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Validate checks the config for missing or malformed values, returning an
+// error naming the offending key.
+func (c *Config) Validate() error {
+	if len(c.TitleProperties) == 0 {
+		return fmt.Errorf("titleProperties: at least one property is required")
+	}
+	for i, uri := range c.TitleProperties {
+		if !isAbsoluteURI(uri) {
+			return fmt.Errorf("titleProperties[%d]: not an absolute URI: %q", i, uri)
+		}
+	}
+	for i, lang := range c.Languages {
+		if !languageTagRegex.MatchString(lang) {
+			return fmt.Errorf("languages[%d]: not a valid language tag: %q", i, lang)
+		}
+	}
+	if !containsString(languageModes, c.LanguageMode) {
+		return fmt.Errorf("languageMode: unknown mode %q (expected one of: %s)", c.LanguageMode, str.Join(languageModes, ", "))
+	}
+	for _, ns := range sortedKeys(c.NamespaceAbbreviations) {
+		if !isAbsoluteURI(ns) {
+			return fmt.Errorf("namespaceAbbreviations[%q]: not an absolute URI", ns)
+		}
+		if c.NamespaceAbbreviations[ns] == "" {
+			return fmt.Errorf("namespaceAbbreviations[%q]: empty abbreviation", ns)
+		}
+	}
+	if !containsString(abbreviationPolicies, c.AbbreviationPolicy) {
+		return fmt.Errorf("abbreviationPolicy: unknown policy %q (expected one of: %s)", c.AbbreviationPolicy, str.Join(abbreviationPolicies, ", "))
+	}
+	if c.AbbreviationSeparator == "" || str.ContainsAny(c.AbbreviationSeparator, "[]{}|#<>") {
+		return fmt.Errorf("abbreviationSeparator: must be non-empty and can not contain any of []{}|#<>")
+	}
+	if !containsString(titleCollisionStrategies, c.TitleCollisionStrategy) {
+		return fmt.Errorf("titleCollisionStrategy: unknown strategy %q (expected one of: %s)", c.TitleCollisionStrategy, str.Join(titleCollisionStrategies, ", "))
+	}
+	for i, uri := range c.PropertyTypes {
+		if !isAbsoluteURI(uri) {
+			return fmt.Errorf("propertyTypes[%d]: not an absolute URI: %q", i, uri)
+		}
+	}
+	for i, uri := range c.CategoryTypes {
+		if !isAbsoluteURI(uri) {
+			return fmt.Errorf("categoryTypes[%d]: not an absolute URI: %q", i, uri)
+		}
+	}
+	for _, dt := range sortedKeys(c.DataTypes) {
+		if !isAbsoluteURI(dt) {
+			return fmt.Errorf("dataTypes[%q]: not an absolute URI", dt)
+		}
+		if !isSMWType(c.DataTypes[dt]) {
+			return fmt.Errorf("dataTypes[%q]: unknown SMW type %q (expected one of: %s)", dt, c.DataTypes[dt], str.Join(smwTypes, ", "))
+		}
+	}
+	for _, smwType := range sortedKeys(c.ValueEscaping) {
+		if !isSMWType(smwType) {
+			return fmt.Errorf("valueEscaping[%q]: unknown SMW type (expected one of: %s)", smwType, str.Join(smwTypes, ", "))
+		}
+		if !containsString(valueEscapings, c.ValueEscaping[smwType]) {
+			return fmt.Errorf("valueEscaping[%q]: unknown escaping %q (expected one of: %s)", smwType, c.ValueEscaping[smwType], str.Join(valueEscapings, ", "))
+		}
+	}
+	if !containsString(typePolicies, c.TypeConflictPolicy) {
+		return fmt.Errorf("typeConflictPolicy: unknown policy %q (expected one of: %s)", c.TypeConflictPolicy, str.Join(typePolicies, ", "))
+	}
+	if c.Templates.CategoriesParam == "" {
+		return fmt.Errorf("templates.categoriesParam: must not be empty")
+	}
+	if c.Templates.ValueSeparator == "" {
+		return fmt.Errorf("templates.valueSeparator: must not be empty")
+	}
+	if str.ContainsAny(c.Templates.ValueSeparator, "|={}[]") {
+		return fmt.Errorf("templates.valueSeparator: must not contain any of the characters |={}[]")
+	}
+	for i, uri := range c.Redirects.Properties {
+		if !isAbsoluteURI(uri) {
+			return fmt.Errorf("redirects.properties[%d]: not an absolute URI: %q", i, uri)
+		}
+	}
+	for i, uri := range c.CategoryPages.DescriptionProperties {
+		if !isAbsoluteURI(uri) {
+			return fmt.Errorf("categoryPages.descriptionProperties[%d]: not an absolute URI: %q", i, uri)
+		}
+	}
+	if str.ContainsAny(c.CategoryPages.FormNamespace, ":#|[]{}<>") {
+		return fmt.Errorf("categoryPages.formNamespace: must not contain any of the characters :#|[]{}<>")
+	}
+	namespaceKeys := make(map[int]bool)
+	for i, ns := range c.SiteInfo.Namespaces {
+		if namespaceKeys[ns.Key] {
+			return fmt.Errorf("siteInfo.namespaces[%d]: duplicate key %d", i, ns.Key)
+		}
+		namespaceKeys[ns.Key] = true
+		if (ns.Key == 0) != (ns.Name == "") {
+			return fmt.Errorf("siteInfo.namespaces[%d]: only the main namespace (0) can have an empty name", i)
+		}
+	}
+	for _, key := range []int{0, 10, 14, 102} {
+		if !namespaceKeys[key] {
+			return fmt.Errorf("siteInfo.namespaces: namespace %d is missing", key)
+		}
+	}
+	return nil
+}
+
+// LookupPredicates returns the predicates needed to look up the title and
+// type of a resource referenced from another page: the title properties,
+// rdf:type, rdfs:subClassOf, and those needed to read enumerated ranges
+// (owl:oneOf and the rdf:first / rdf:rest of its list). Indexing only triples
+// with these predicates gives a lookup index much smaller than the full
+// graph.
+func (c *Config) LookupPredicates() []string {
+	preds := append([]string{}, c.TitleProperties...)
+	return append(preds, typePropertyURI, subClassPropertyURI, oneOfPropertyURI, rdfFirstURI, rdfRestURI)
+}
+
+func isAbsoluteURI(uri string) bool {
+	colonPos := str.Index(uri, ":")
+	return colonPos > 0 && !str.ContainsAny(uri, " <>\"{}|\\^`")
+}
+
+func isSMWType(typeName string) bool {
+	return containsString(smwTypes, typeName)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,148 @@
+package components
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDefaultConfig tests that the default config is valid
+func TestDefaultConfig(t *testing.T) {
+	conf := DefaultConfig()
+	if err := conf.Validate(); err != nil {
+		t.Error("Default config does not validate: ", err.Error())
+	}
+	if !conf.Templates.Enabled {
+		t.Error("Templates are not enabled in default config")
+	}
+}
+
+// TestParseConfig tests that values from the JSON override the defaults
+func TestParseConfig(t *testing.T) {
+	configJSON := `{
+	"titleProperties": ["http://example.org/name"],
+	"dataTypes": {"http://www.w3.org/2001/XMLSchema#boolean": "Boolean"},
+	"templates": {"enabled": false}
+}`
+	conf, err := ParseConfig(strings.NewReader(configJSON))
+	if err != nil {
+		t.Fatal("Could not parse config: ", err.Error())
+	}
+	if len(conf.TitleProperties) != 1 || conf.TitleProperties[0] != "http://example.org/name" {
+		t.Error("titleProperties not replaced by config value")
+	}
+	if conf.DataTypes["http://www.w3.org/2001/XMLSchema#boolean"] != "Boolean" {
+		t.Error("dataTypes entry from config not added")
+	}
+	if conf.DataTypes[dataTypeURIInteger] != "Number" {
+		t.Error("Default dataTypes entry missing after merge")
+	}
+	if conf.Templates.Enabled {
+		t.Error("templates.enabled not overridden by config")
+	}
+	if conf.Templates.ValueSeparator != "," {
+		t.Error("templates.valueSeparator default lost")
+	}
+}
+
+// TestParseYAMLConfig tests that a YAML config is read like the JSON one
+func TestParseYAMLConfig(t *testing.T) {
+	configYAML := `
+titleProperties:
+  - http://example.org/name
+dataTypes:
+  http://www.w3.org/2001/XMLSchema#boolean: Boolean
+templates:
+  enabled: false
+siteInfo:
+  namespaces:
+    - {key: 0, name: ""}
+    - {key: 10, name: Template}
+    - {key: 14, name: Category}
+    - {key: 102, name: Property}
+`
+	conf, err := ParseYAMLConfig(strings.NewReader(configYAML))
+	if err != nil {
+		t.Fatal("Could not parse config: ", err.Error())
+	}
+	if len(conf.TitleProperties) != 1 || conf.TitleProperties[0] != "http://example.org/name" {
+		t.Error("titleProperties not replaced by config value")
+	}
+	if conf.DataTypes["http://www.w3.org/2001/XMLSchema#boolean"] != "Boolean" || conf.DataTypes[dataTypeURIInteger] != "Number" {
+		t.Error("dataTypes not merged with the defaults")
+	}
+	if conf.Templates.Enabled || conf.Templates.ValueSeparator != "," {
+		t.Error("templates not overridden by config")
+	}
+	if len(conf.SiteInfo.Namespaces) != 4 || conf.SiteInfo.Namespaces[2].Key != 14 {
+		t.Errorf("siteInfo.namespaces not read: %v", conf.SiteInfo.Namespaces)
+	}
+
+	if conf, err := ParseYAMLConfig(strings.NewReader("")); err != nil || conf.LanguageMode != DefaultConfig().LanguageMode {
+		t.Error("Empty YAML config does not give the defaults")
+	}
+	for configYAML, key := range map[string]string{
+		"titleProperty: [http://www.w3.org/2000/01/rdf-schema#label]": "titleProperty",
+		"languageMode: mixed": "languageMode",
+	} {
+		if _, err := ParseYAMLConfig(strings.NewReader(configYAML)); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error naming %s, got: %v", key, err)
+		}
+	}
+}
+
+// TestLoadConfig tests that the config format is chosen by file extension
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"mapping.json": `{"languageMode": "monolingual"}`,
+		"mapping.yaml": "languageMode: monolingual\n",
+		"mapping.YML":  "languageMode: monolingual\n",
+	}
+	for fileName, content := range files {
+		path := filepath.Join(dir, fileName)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Could not write config file: ", err.Error())
+		}
+		conf, err := LoadConfig(path)
+		if err != nil {
+			t.Errorf("Could not load %s: %s", fileName, err.Error())
+		} else if conf.LanguageMode != LanguageModeMonolingual {
+			t.Errorf("languageMode not read from %s", fileName)
+		}
+	}
+}
+
+// TestParseConfigErrors tests that validation errors point at the offending key
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"titleProperties": ["label"]}`:                                      "titleProperties[0]",
+		`{"dataTypes": {"http://example.org/dt": "Nmber"}}`:                   `dataTypes["http://example.org/dt"]`,
+		`{"namespaceAbbreviations": {"http://example.org/": ""}}`:             `namespaceAbbreviations["http://example.org/"]`,
+		`{"templates": {"valueSeparator": "|"}}`:                              "templates.valueSeparator",
+		`{"titleProperty": ["http://www.w3.org/2000/01/rdf-schema#label"]}`:   "titleProperty",
+		`{"categoryTypes": ["http://www.w3.org/2002/07/owl#Class", "Class"]}`: "categoryTypes[1]",
+		`{"languages": ["en", "en_GB"]}`:                                      "languages[1]",
+		`{"languageMode": "mixed"}`:                                           "languageMode",
+		`{"typeConflictPolicy": "loudest"}`:                                   "typeConflictPolicy",
+		`{"abbreviationPolicy": "sometimes"}`:                                 "abbreviationPolicy",
+		`{"abbreviationSeparator": "|"}`:                                      "abbreviationSeparator",
+		`{"titleCollisionStrategy": "random"}`:                                "titleCollisionStrategy",
+		`{"valueEscaping": {"Txt": "entities"}}`:                              `valueEscaping["Txt"]`,
+		`{"valueEscaping": {"Code": "cdata"}}`:                                `valueEscaping["Code"]`,
+		`{"redirects": {"properties": ["altLabel"]}}`:                         "redirects.properties[0]",
+		`{"categoryPages": {"descriptionProperties": ["comment"]}}`:           "categoryPages.descriptionProperties[0]",
+		`{"categoryPages": {"formNamespace": "Form:"}}`:                       "categoryPages.formNamespace",
+		`{"siteInfo": {"namespaces": [{"key": 0, "name": ""}]}}`:              "siteInfo.namespaces",
+		`{"siteInfo": {"namespaces": [{"key": 1, "name": ""}]}}`:              "siteInfo.namespaces[0]",
+	}
+	for configJSON, expectedKey := range tests {
+		_, err := ParseConfig(strings.NewReader(configJSON))
+		if err == nil {
+			t.Errorf("No error for invalid config %s", configJSON)
+		} else if !strings.Contains(err.Error(), expectedKey) {
+			t.Errorf("Error for config %s does not mention key %s: %s", configJSON, expectedKey, err.Error())
+		}
+	}
+}
@@ -3,3 +3,7 @@ package components
 const (
 	BUFSIZE = 16
 )
+
+// StdioFileName is the file name used to denote reading from standard input,
+// or writing to standard output, instead of a file.
+const StdioFileName = "-"
@@ -0,0 +1,231 @@
+package components
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	str "strings"
+	"time"
+)
+
+// --------------------------------------------------------------------------------
+// Datatypes
+// --------------------------------------------------------------------------------
+
+const (
+	xsdNS = "http://www.w3.org/2001/XMLSchema#"
+
+	dataTypeURIString     = xsdNS + "string"
+	dataTypeURILangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
+	dataTypeURIInteger    = xsdNS + "integer"
+	dataTypeURIFloat      = xsdNS + "float"
+	dataTypeURIDecimal    = xsdNS + "decimal"
+	dataTypeURIDouble     = xsdNS + "double"
+	dataTypeURIBoolean    = xsdNS + "boolean"
+	dataTypeURIDate       = xsdNS + "date"
+	dataTypeURIDateTime   = xsdNS + "dateTime"
+	dataTypeURIGYear      = xsdNS + "gYear"
+	dataTypeURIAnyURI     = xsdNS + "anyURI"
+	dataTypeURIWKT        = "http://www.opengis.net/ont/geosparql#wktLiteral"
+	dataTypeURIVirtGeom   = "http://www.openlinksw.com/schemas/virtrdf#Geometry"
+	dataTypeURIUCUM       = "https://w3id.org/cdt/ucum"
+	dataTypeURILinDTUCUM  = "http://w3id.org/lindt/custom_datatypes#ucum"
+)
+
+// Names of the SMW types that values are normalised for.
+const (
+	smwTypeText      = "Text"
+	smwTypeNumber    = "Number"
+	smwTypeBoolean   = "Boolean"
+	smwTypeDate      = "Date"
+	smwTypeURL       = "URL"
+	smwTypeGeo       = "Geographic coordinate"
+	smwTypeQuantity  = "Quantity"
+	smwTypeTelephone = "Telephone"
+	smwTypeEmail     = "Email"
+	smwTypePage      = "Page"
+)
+
+// defaultDataTypes maps literal datatype URIs to the SMW type used for
+// properties having values of that datatype.
+func defaultDataTypes() map[string]string {
+	dataTypes := map[string]string{
+		dataTypeURILangString: smwTypeText,
+		dataTypeURIAnyURI:     smwTypeURL,
+		dataTypeURIWKT:        smwTypeGeo,
+		dataTypeURIVirtGeom:   smwTypeGeo,
+		dataTypeURIUCUM:       smwTypeQuantity,
+		dataTypeURILinDTUCUM:  smwTypeQuantity,
+	}
+	for _, name := range []string{"string", "normalizedString", "token", "language", "Name", "NCName"} {
+		dataTypes[xsdNS+name] = smwTypeText
+	}
+	for _, name := range []string{
+		"integer", "int", "long", "short", "byte",
+		"nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger",
+		"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte",
+		"decimal", "float", "double",
+	} {
+		dataTypes[xsdNS+name] = smwTypeNumber
+	}
+	dataTypes[dataTypeURIBoolean] = smwTypeBoolean
+	for _, name := range []string{"date", "dateTime", "dateTimeStamp", "gYear", "gYearMonth"} {
+		dataTypes[xsdNS+name] = smwTypeDate
+	}
+	return dataTypes
+}
+
+// iriSchemeTypes maps URI schemes to the SMW type used for properties having
+// IRI values with that scheme, rather than linking to a page.
+var iriSchemeTypes = map[string]string{
+	"mailto:": smwTypeEmail,
+	"tel:":    smwTypeTelephone,
+}
+
+// iriValueType returns the SMW type for an IRI value, if it has one of the
+// schemes in iriSchemeTypes.
+func iriValueType(iri string) (string, bool) {
+	for scheme, smwType := range iriSchemeTypes {
+		if str.HasPrefix(str.ToLower(iri), scheme) {
+			return smwType, true
+		}
+	}
+	return "", false
+}
+
+// --------------------------------------------------------------------------------
+// Value normalisation
+// --------------------------------------------------------------------------------
+
+// normalizeValue converts the lexical form of an RDF value into a form
+// accepted by SMW for the type smwType. Values that can not be normalised are
+// returned as is (trimmed of surrounding whitespace), and will show up as
+// invalid values in the wiki.
+func normalizeValue(smwType string, value string) string {
+	value = str.TrimSpace(value)
+	switch smwType {
+	case smwTypeNumber:
+		return normalizeNumber(value)
+	case smwTypeBoolean:
+		return normalizeBoolean(value)
+	case smwTypeDate:
+		return normalizeDate(value)
+	case smwTypeURL:
+		return str.Replace(value, " ", "%20", -1)
+	case smwTypeGeo:
+		return normalizeGeo(value)
+	case smwTypeQuantity:
+		return str.Join(str.Fields(value), " ")
+	case smwTypeEmail:
+		return trimScheme(value, "mailto:")
+	case smwTypeTelephone:
+		return trimScheme(value, "tel:")
+	}
+	return value
+}
+
+// normalizeNumber converts an XSD number (such as "+1.0E3", ".5" or "007")
+// into the format used by SMW.
+func normalizeNumber(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return value
+	}
+	sign := ""
+	if str.HasPrefix(value, "-") {
+		sign = "-"
+	}
+	value = str.TrimLeft(value, "+-")
+	mantissa, exponent := value, ""
+	if i := str.IndexAny(value, "eE"); i >= 0 {
+		mantissa, exponent = value[:i], "e"+str.TrimPrefix(value[i+1:], "+")
+	}
+	intPart, fracPart := mantissa, ""
+	if i := str.Index(mantissa, "."); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	intPart = str.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	if exponent == "" {
+		fracPart = str.TrimRight(fracPart, "0")
+	}
+	if f == 0 {
+		sign = ""
+	}
+	if fracPart != "" {
+		return sign + intPart + "." + fracPart + exponent
+	}
+	return sign + intPart + exponent
+}
+
+// normalizeBoolean converts an XSD boolean ("true", "false", "1" or "0") into
+// the format used by SMW.
+func normalizeBoolean(value string) string {
+	switch value {
+	case "true", "1":
+		return "true"
+	case "false", "0":
+		return "false"
+	}
+	return value
+}
+
+var (
+	xsdTimezoneRegex = regexp.MustCompile(`(Z|[+-]\d\d:\d\d)$`)
+	xsdYearRegex     = regexp.MustCompile(`^(-?)0*(\d+)((?:-\d\d){0,2})$`)
+)
+
+// normalizeDate converts an XSD date, dateTime, gYear or gYearMonth into the
+// format used by SMW. Date-times are converted to UTC, since SMW does not
+// keep time zones. Years before year 1 are written with "BC".
+func normalizeDate(value string) string {
+	if str.Contains(value, "T") {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t.UTC().Format("2006-01-02T15:04:05")
+		}
+		if t, err := time.Parse("2006-01-02T15:04:05.999999999", value); err == nil {
+			return t.Format("2006-01-02T15:04:05")
+		}
+		return value
+	}
+	date := xsdTimezoneRegex.ReplaceAllString(value, "")
+	m := xsdYearRegex.FindStringSubmatch(date)
+	if m == nil {
+		return value
+	}
+	if m[1] == "-" {
+		// XSD 1.1 counts 1 BC as year 0000, -0001 as 2 BC, etc.
+		year, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%d BC", year+1)
+	}
+	year := m[2]
+	if year == "0" && m[3] == "" {
+		return "1 BC"
+	}
+	for len(year) < 4 {
+		year = "0" + year
+	}
+	return year + m[3]
+}
+
+var wktPointRegex = regexp.MustCompile(`(?i)^(?:<[^>]*>\s*)?POINT\s*(?:Z\s*|M\s*|ZM\s*)?\(\s*([-+0-9.eE]+)\s+([-+0-9.eE]+)(?:\s+[-+0-9.eE]+)*\s*\)$`)
+
+// normalizeGeo converts a WKT point ("POINT(longitude latitude)", optionally
+// preceded by a CRS URI) into the "latitude, longitude" format used by SMW.
+func normalizeGeo(value string) string {
+	m := wktPointRegex.FindStringSubmatch(value)
+	if m == nil {
+		return value
+	}
+	return normalizeNumber(m[2]) + ", " + normalizeNumber(m[1])
+}
+
+func trimScheme(value string, scheme string) string {
+	if str.HasPrefix(str.ToLower(value), scheme) {
+		return value[len(scheme):]
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+package components
+
+import "testing"
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		smwType  string
+		value    string
+		expected string
+	}{
+		{smwTypeNumber, "42", "42"},
+		{smwTypeNumber, "+007", "7"},
+		{smwTypeNumber, "-0.50", "-0.5"},
+		{smwTypeNumber, ".5", "0.5"},
+		{smwTypeNumber, "5.", "5"},
+		{smwTypeNumber, "-0", "0"},
+		{smwTypeNumber, "1.0E+3", "1.0e3"},
+		{smwTypeNumber, "INF", "INF"},
+		{smwTypeNumber, "many", "many"},
+		{smwTypeBoolean, "1", "true"},
+		{smwTypeBoolean, "false", "false"},
+		{smwTypeBoolean, "0", "false"},
+		{smwTypeDate, "2024-01-31", "2024-01-31"},
+		{smwTypeDate, "2024-01-31Z", "2024-01-31"},
+		{smwTypeDate, "2024-01-31+02:00", "2024-01-31"},
+		{smwTypeDate, "2024-01-31T12:30:00Z", "2024-01-31T12:30:00"},
+		{smwTypeDate, "2024-01-31T12:30:00+02:00", "2024-01-31T10:30:00"},
+		{smwTypeDate, "2024-01-31T12:30:00.123", "2024-01-31T12:30:00"},
+		{smwTypeDate, "1969", "1969"},
+		{smwTypeDate, "0800", "0800"},
+		{smwTypeDate, "1969-07", "1969-07"},
+		{smwTypeDate, "-0043", "44 BC"},
+		{smwTypeDate, "yesterday", "yesterday"},
+		{smwTypeURL, " http://example.org/a b ", "http://example.org/a%20b"},
+		{smwTypeGeo, "POINT(13.4050 52.5200)", "52.52, 13.405"},
+		{smwTypeGeo, "<http://www.opengis.net/def/crs/OGC/1.3/CRS84> Point ( -0.1276 51.5072 )", "51.5072, -0.1276"},
+		{smwTypeGeo, "LINESTRING(0 0, 1 1)", "LINESTRING(0 0, 1 1)"},
+		{smwTypeQuantity, "5.3  km", "5.3 km"},
+		{smwTypeEmail, "mailto:alice@example.org", "alice@example.org"},
+		{smwTypeTelephone, "tel:+46-18-123456", "+46-18-123456"},
+		{smwTypeText, " some text ", "some text"},
+	}
+	for _, tt := range tests {
+		if got := normalizeValue(tt.smwType, tt.value); got != tt.expected {
+			t.Errorf("normalizeValue(%q, %q) = %q, expected %q", tt.smwType, tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestDefaultDataTypes(t *testing.T) {
+	expected := map[string]string{
+		dataTypeURIDecimal:  smwTypeNumber,
+		dataTypeURIDouble:   smwTypeNumber,
+		dataTypeURIBoolean:  smwTypeBoolean,
+		dataTypeURIDate:     smwTypeDate,
+		dataTypeURIDateTime: smwTypeDate,
+		dataTypeURIGYear:    smwTypeDate,
+		dataTypeURIAnyURI:   smwTypeURL,
+		dataTypeURIWKT:      smwTypeGeo,
+		dataTypeURIUCUM:     smwTypeQuantity,
+	}
+	dataTypes := defaultDataTypes()
+	for dataType, smwType := range expected {
+		if dataTypes[dataType] != smwType {
+			t.Errorf("Wrong SMW type for %s (Expected %s, got %s)", dataType, smwType, dataTypes[dataType])
+		}
+	}
+	for dataType, smwType := range dataTypes {
+		if !isSMWType(smwType) {
+			t.Errorf("Unknown SMW type %s for %s", smwType, dataType)
+		}
+	}
+}
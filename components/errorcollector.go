@@ -0,0 +1,196 @@
+package components
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// --------------------------------------------------------------------------------
+// ConversionError
+// --------------------------------------------------------------------------------
+
+// ConversionError is a structured error sent by processes on their OutError
+// port, when some input could not be processed and was skipped.
+type ConversionError struct {
+	// FileName is the file in which the error occurred, if known
+	FileName string
+	// Line is the line number at which the error occurred, or 0 if unknown
+	Line int
+	// Input is the offending input, if known
+	Input string
+	// Err is the underlying error
+	Err error
+}
+
+// NewConversionError returns a new ConversionError. If the message of err
+// starts with a "line:column:" position, as returned by the Turtle parser,
+// the line number is picked up from there.
+func NewConversionError(fileName string, line int, input string, err error) *ConversionError {
+	if line == 0 {
+		if m := errorPositionRegex.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+	}
+	return &ConversionError{
+		FileName: fileName,
+		Line:     line,
+		Input:    input,
+		Err:      err,
+	}
+}
+
+var errorPositionRegex = regexp.MustCompile(`^(\d+):\d+:? `)
+
+func (e *ConversionError) Error() string {
+	msg := ""
+	if e.FileName != "" {
+		msg += e.FileName + ":"
+	}
+	if e.Line > 0 {
+		msg += strconv.Itoa(e.Line) + ":"
+	}
+	if msg != "" {
+		msg += " "
+	}
+	msg += e.Err.Error()
+	if e.Input != "" {
+		msg += fmt.Sprintf(" (input: %q)", e.Input)
+	}
+	return msg
+}
+
+// Policies for how an ErrorCollector handles errors
+const (
+	// ErrorPolicyFail stops reading input at the first error, and makes the
+	// program fail once the data read so far is written
+	ErrorPolicyFail = "fail"
+	// ErrorPolicySkip skips the offending input silently, only reporting
+	// the number of errors at the end
+	ErrorPolicySkip = "skip"
+	// ErrorPolicyLog skips the offending input, logging each error
+	ErrorPolicyLog = "log"
+)
+
+// CheckErrorPolicy returns an error if policy is not one of the known error
+// policies.
+func CheckErrorPolicy(policy string) error {
+	switch policy {
+	case ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyLog:
+		return nil
+	}
+	return fmt.Errorf("unknown error policy %q (expected one of: %s, %s, %s)", policy, ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyLog)
+}
+
+// --------------------------------------------------------------------------------
+// ErrorCollector
+// --------------------------------------------------------------------------------
+
+// ErrorCollector is a process that receives *ConversionError's on all the
+// error ports connected to it, and handles them according to its error
+// policy. When all connected ports are closed, it writes a summary of the
+// errors, and sends a done signal on OutDone.
+//
+// With the fail policy, the first error is reported, and the Stop channel is
+// closed, telling the readers connected to it to stop reading, so that the
+// pipeline drains and the writers finish their files. Later errors are then
+// received but not reported, and Failed tells that the program should fail.
+type ErrorCollector struct {
+	OutDone chan interface{}
+	Stop    chan struct{}
+	Policy  string
+	inPorts []chan *ConversionError
+	errCnt  int
+	errCnts map[string]int
+	failed  bool
+	out     io.Writer
+}
+
+// NewErrorCollector returns an initialized ErrorCollector, using the error
+// policy policy, and writing to stderr.
+func NewErrorCollector(policy string) *ErrorCollector {
+	return &ErrorCollector{
+		OutDone: make(chan interface{}, BUFSIZE),
+		Stop:    make(chan struct{}),
+		Policy:  policy,
+		inPorts: []chan *ConversionError{},
+		errCnts: make(map[string]int),
+		out:     os.Stderr,
+	}
+}
+
+// Connect connects an error port to the ErrorCollector.
+func (p *ErrorCollector) Connect(ch chan *ConversionError) {
+	p.inPorts = append(p.inPorts, ch)
+}
+
+// Run runs the ErrorCollector process.
+func (p *ErrorCollector) Run() {
+	defer close(p.OutDone)
+
+	merged := make(chan *ConversionError, BUFSIZE)
+	wg := &sync.WaitGroup{}
+	for _, inPort := range p.inPorts {
+		wg.Add(1)
+		go func(inPort chan *ConversionError) {
+			defer wg.Done()
+			for err := range inPort {
+				merged <- err
+			}
+		}(inPort)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	for err := range merged {
+		p.errCnt++
+		p.errCnts[err.FileName]++
+		switch p.Policy {
+		case ErrorPolicyFail:
+			if !p.failed {
+				fmt.Fprintln(p.out, "Error:", err.Error())
+				fmt.Fprintf(p.out, "Stopping at first error (use -on-error=skip or -on-error=log to skip errors instead)\n")
+				p.failed = true
+				close(p.Stop)
+			}
+		case ErrorPolicyLog:
+			fmt.Fprintln(p.out, "Skipping:", err.Error())
+		}
+	}
+
+	if p.errCnt > 0 && !p.failed {
+		fmt.Fprintf(p.out, "Finished with %d error(s), for which data was skipped:\n", p.errCnt)
+		fileNames := []string{}
+		for fileName := range p.errCnts {
+			fileNames = append(fileNames, fileName)
+		}
+		sort.Strings(fileNames)
+		for _, fileName := range fileNames {
+			name := fileName
+			if name == "" {
+				name = "(unknown file)"
+			}
+			fmt.Fprintf(p.out, "\t%s: %d\n", name, p.errCnts[fileName])
+		}
+	}
+
+	p.OutDone <- &DoneSignal{}
+}
+
+// ErrorCount returns the number of errors received. It should only be called
+// after the process has finished.
+func (p *ErrorCollector) ErrorCount() int {
+	return p.errCnt
+}
+
+// Failed tells whether an error made the conversion stop, with the fail
+// policy. It should only be called after the process has finished.
+func (p *ErrorCollector) Failed() bool {
+	return p.failed
+}
@@ -0,0 +1,126 @@
+package components
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+)
+
+// TestConversionError tests the formatting of ConversionError
+func TestConversionError(t *testing.T) {
+	err := NewConversionError("data.ttl", 0, "", errors.New("2:30: syntax error"))
+	if err.Line != 2 {
+		t.Errorf("Line number not picked up from error message: %d", err.Line)
+	}
+	err = NewConversionError("data.nt", 12, "<s> <p> bad .", errors.New("syntax error"))
+	expected := `data.nt:12: syntax error (input: "<s> <p> bad .")`
+	if err.Error() != expected {
+		t.Errorf("Wrong error message: %s (expected %s)", err.Error(), expected)
+	}
+}
+
+// TestErrorCollector tests that errors from all ports are collected and
+// summarized
+func TestErrorCollector(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	for _, policy := range []string{ErrorPolicySkip, ErrorPolicyLog} {
+		out := &bytes.Buffer{}
+		ec := NewErrorCollector(policy)
+		ec.out = out
+		errPort1 := make(chan *ConversionError, BUFSIZE)
+		errPort2 := make(chan *ConversionError, BUFSIZE)
+		ec.Connect(errPort1)
+		ec.Connect(errPort2)
+
+		errPort1 <- NewConversionError("a.nt", 1, "", errors.New("error one"))
+		errPort1 <- NewConversionError("a.nt", 2, "", errors.New("error two"))
+		errPort2 <- NewConversionError("b.nt", 3, "", errors.New("error three"))
+		close(errPort1)
+		close(errPort2)
+
+		go ec.Run()
+		<-ec.OutDone
+
+		if ec.ErrorCount() != 3 {
+			t.Errorf("Wrong error count for policy %s: %d", policy, ec.ErrorCount())
+		}
+		if !strings.Contains(out.String(), "a.nt: 2") || !strings.Contains(out.String(), "b.nt: 1") {
+			t.Errorf("Summary for policy %s is missing per-file counts:\n%s", policy, out.String())
+		}
+		logged := strings.Contains(out.String(), "error two")
+		if policy == ErrorPolicyLog && !logged {
+			t.Error("Error not logged with policy log")
+		}
+		if policy == ErrorPolicySkip && logged {
+			t.Error("Error logged with policy skip")
+		}
+	}
+}
+
+// TestErrorCollectorFail tests that the fail policy reports only the first
+// error, signals the readers to stop, and keeps receiving errors until its
+// ports are closed
+func TestErrorCollectorFail(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	out := &bytes.Buffer{}
+	ec := NewErrorCollector(ErrorPolicyFail)
+	ec.out = out
+	errPort := make(chan *ConversionError, BUFSIZE)
+	ec.Connect(errPort)
+	errPort <- NewConversionError("a.nt", 1, "", errors.New("error one"))
+	errPort <- NewConversionError("a.nt", 2, "", errors.New("error two"))
+	close(errPort)
+
+	go ec.Run()
+	<-ec.OutDone
+
+	if !ec.Failed() {
+		t.Error("Collector not failed with fail policy")
+	}
+	select {
+	case <-ec.Stop:
+	default:
+		t.Error("Stop not closed with fail policy")
+	}
+	if !strings.Contains(out.String(), "error one") || strings.Contains(out.String(), "error two") {
+		t.Errorf("Only the first error should be reported:\n%s", out.String())
+	}
+}
+
+// Tests that the RDFFileReader skips bad triples and reports them
+func TestRDFFileReaderErrors(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fr := NewRDFFileReader(nil)
+	fr.stdin = strings.NewReader("<http://example.org/s1> <http://example.org/p1> \"o1\" .\n<http://example.org/s1> <http://example.org/p1> bad .\n<http://example.org/s1> <http://example.org/p1> \"o3\" .\n")
+	fr.Format = RDFFormatNTriples
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- StdioFileName
+	}()
+	go fr.Run()
+
+	triples := []string{}
+	for tr := range fr.OutTriple {
+		triples = append(triples, tr.Obj.String())
+	}
+	errs := []*ConversionError{}
+	for err := range fr.OutError {
+		errs = append(errs, err)
+	}
+
+	if len(triples) != 2 || triples[0] != "o1" || triples[1] != "o3" {
+		t.Errorf("Wrong triples read around bad triple: %v", triples)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Wrong number of errors reported: %d", len(errs))
+	}
+	if errs[0].FileName != StdioFileName {
+		t.Errorf("Wrong file name in error: %s", errs[0].FileName)
+	}
+}
@@ -0,0 +1,199 @@
+package components
+
+import (
+	"regexp"
+	"strconv"
+	str "strings"
+	"unicode/utf8"
+)
+
+// Text goes through two separate layers of escaping on its way into the
+// generated XML:
+//
+// 1. Wikitext escaping (escapeWikiValue), of values put into wikitext, so that
+//    they are not read as markup. This is done when the wikitext of a page is
+//    built, and never on titles, which are made valid by mwtitle instead.
+// 2. XML escaping, of everything written to the XML (titles and wikitext
+//    alike). Characters that XML can not hold are replaced by sanitizeXMLText
+//    when the export elements are built, and markup characters (&, <, >, ")
+//    are escaped by encoding/xml when the elements are marshalled.
+//
+// Wikitext escaping thus produces plain text that may contain &, < and > (as
+// in "&lt;"), which XML escaping then escapes once more, so that the text
+// given to MediaWiki on import is exactly the wikitext.
+
+// Strategies for escaping values in wikitext, chosen per SMW type by
+// Config.ValueEscaping
+const (
+	// ValueEscapingEntities writes the characters that would be read as
+	// markup as character references (such as "&#124;" for "|"), which SMW
+	// decodes, so that the stored value equals the literal.
+	ValueEscapingEntities = "entities"
+	// ValueEscapingNowiki wraps values in <nowiki> tags, keeping them
+	// readable in the wikitext. SMW must be set up to unstrip nowiki tags in
+	// values for the stored value to equal the literal.
+	ValueEscapingNowiki = "nowiki"
+	// ValueEscapingSubstitute replaces the characters that would be read as
+	// markup by similar ones (such as "(" for "["), which changes the value,
+	// but keeps page titles valid.
+	ValueEscapingSubstitute = "substitute"
+)
+
+var valueEscapings = []string{ValueEscapingEntities, ValueEscapingNowiki, ValueEscapingSubstitute}
+
+// wikiMarkupEntities holds the character references for the characters that
+// can end a value in an annotation, template parameter or parser function
+// parameter, or start markup, when not escaped. Newlines are included, since
+// tables and headings are parsed before SMW reads annotations.
+var wikiMarkupEntities = map[rune]string{
+	'<':  "&lt;",
+	'>':  "&gt;",
+	'[':  "&#91;",
+	']':  "&#93;",
+	'{':  "&#123;",
+	'}':  "&#125;",
+	'|':  "&#124;",
+	'\n': "&#10;",
+	'\r': "&#13;",
+}
+
+// magicWordRegex matches behavior switches such as __NOTOC__, which are
+// removed from the wikitext before SMW reads annotations.
+var magicWordRegex = regexp.MustCompile(`__([A-Za-z]+)__`)
+
+// escapeWikiValue escapes the value inStr of the SMW type smwType for use in
+// wikitext, with the strategy chosen for the type in escapings (by default
+// ValueEscapingEntities). If separator is not empty, occurrences of it are
+// escaped as well, so that multiple values can be separated by it. When the
+// separator has characters used in character references (such as ";"),
+// values that would need character references are wrapped in nowiki tags
+// instead, so that they are not split at the references.
+func escapeWikiValue(inStr string, smwType string, escapings map[string]string, separator string) string {
+	escaping, ok := escapings[smwType]
+	if !ok {
+		escaping = ValueEscapingEntities
+	}
+	if escaping == ValueEscapingEntities && separator != "" && str.ContainsAny(separator, characterReferenceChars) {
+		if escaped := escapeWikiValue(inStr, smwType, escapings, ""); escaped != inStr || str.Contains(inStr, separator) {
+			escaping = ValueEscapingNowiki
+		}
+	}
+	if escaping == ValueEscapingSubstitute {
+		// Substitution keeps no separators either
+		outStr := escapeWikiChars(inStr)
+		if separator != "" {
+			outStr = str.Replace(outStr, separator, " ", -1)
+		}
+		return outStr
+	}
+	if escaping == ValueEscapingEntities && separator != "" && str.Contains(inStr, separator) {
+		// Escape the parts between separators, so that the escaping of the
+		// separators is not escaped again
+		parts := str.Split(inStr, separator)
+		for i, part := range parts {
+			parts[i] = escapeWikiValue(part, smwType, escapings, "")
+		}
+		return str.Join(parts, characterReferences(separator))
+	}
+	if escaping == ValueEscapingNowiki {
+		// Separators in nowiki tags are hidden from the template parser
+		outStr := htmlEntityRegex.ReplaceAllString(inStr, "&amp;$1;")
+		outStr = str.Replace(outStr, "<", "&lt;", -1)
+		return "<nowiki>" + outStr + "</nowiki>"
+	}
+	outStr := &str.Builder{}
+	for _, r := range htmlEntityRegex.ReplaceAllString(inStr, "&amp;$1;") {
+		if entity, ok := wikiMarkupEntities[r]; ok {
+			outStr.WriteString(entity)
+		} else {
+			outStr.WriteRune(r)
+		}
+	}
+	return escapeDoubleColons(magicWordRegex.ReplaceAllString(outStr.String(), "&#95;_${1}__"))
+}
+
+// escapeDoubleColons writes the colons of "::" as character references, so
+// that a value with "::" does not make a chained annotation, as in
+// [[P::a::b]], which gives the value b to both P and a.
+func escapeDoubleColons(inStr string) string {
+	for str.Contains(inStr, "::") {
+		inStr = str.Replace(inStr, "::", "&#58;:", -1)
+	}
+	return inStr
+}
+
+// characterReferenceChars holds the characters used in character references.
+const characterReferenceChars = "&#;0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// characterReferences writes all the characters of inStr as numeric
+// character references.
+func characterReferences(inStr string) string {
+	outStr := ""
+	for _, r := range inStr {
+		outStr += "&#" + strconv.Itoa(int(r)) + ";"
+	}
+	return outStr
+}
+
+// htmlEntityRegex matches what MediaWiki reads as an HTML entity or character
+// reference in wikitext.
+var htmlEntityRegex = regexp.MustCompile(`&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+
+// escapeWikiChars escapes the value inStr for use in wikitext, in facts and
+// template parameters (the ValueEscapingSubstitute strategy): brackets, pipes
+// and equal signs are replaced, so that they do not end the fact or
+// parameter, < and > are written as entities, so
+// that they do not start HTML tags, as are colons in "::", so that they do not
+// chain annotations, and ampersands starting something read as an entity are
+// written as entities, so that literal entities (such as "&amp;") are kept.
+func escapeWikiChars(inStr string) string {
+	outStr := htmlEntityRegex.ReplaceAllString(inStr, "&amp;$1;")
+	outStr = str.Replace(outStr, "[", "(", -1)
+	outStr = str.Replace(outStr, "]", ")", -1)
+	outStr = str.Replace(outStr, "|", ",", -1)
+	outStr = str.Replace(outStr, "=", "-", -1)
+	outStr = str.Replace(outStr, "<", "&lt;", -1)
+	outStr = str.Replace(outStr, ">", "&gt;", -1)
+	return escapeDoubleColons(outStr)
+}
+
+// sanitizeXMLText replaces the invalid UTF-8 sequences in inStr, and the
+// characters that are not allowed in XML 1.0 (such as most control
+// characters), with the replacement character U+FFFD.
+func sanitizeXMLText(inStr string) string {
+	if isXMLText(inStr) {
+		return inStr
+	}
+	outStr := &str.Builder{}
+	for i := 0; i < len(inStr); {
+		r, width := utf8.DecodeRuneInString(inStr[i:])
+		if (r == utf8.RuneError && width == 1) || !isXMLChar(r) {
+			r = utf8.RuneError
+		}
+		outStr.WriteRune(r)
+		i += width
+	}
+	return outStr.String()
+}
+
+// isXMLText tells whether inStr is valid UTF-8 and only contains characters
+// allowed in XML 1.0.
+func isXMLText(inStr string) bool {
+	if !utf8.ValidString(inStr) {
+		return false
+	}
+	for _, r := range inStr {
+		if !isXMLChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isXMLChar tells whether r is allowed in XML 1.0 (the Char production).
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
@@ -0,0 +1,248 @@
+package components
+
+import (
+	"bufio"
+	"encoding/xml"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+)
+
+func TestEscapeWikiChars(t *testing.T) {
+	tests := map[string]string{
+		"R&D":                  "R&D",
+		"Fish &amp; chips":     "Fish &amp;amp; chips",
+		"&#124; and &#x7C;":    "&amp;#124; and &amp;#x7C;",
+		"a < b > c":            "a &lt; b &gt; c",
+		"<nowiki>":             "&lt;nowiki&gt;",
+		"[[Link]] | x = y":     "((Link)) , x - y",
+		"]]> end of CDATA":     "))&gt; end of CDATA",
+		"& ; &; &x y;":         "& ; &; &x y;",
+		"Ünïcödé ☃ stays 𝄞":    "Ünïcödé ☃ stays 𝄞",
+		"tab\tand\nnewline\r.": "tab\tand\nnewline\r.",
+		"std::vector :::":      "std&#58;:vector &#58;&#58;:",
+	}
+	for value, expected := range tests {
+		if escaped := escapeWikiChars(value); escaped != expected {
+			t.Errorf("Wrong wikitext escaping of %q (Expected %q, got %q)", value, expected, escaped)
+		}
+	}
+}
+
+// TestEscapeWikiValueCorpus tests, for the literals in the test corpus, that
+// escaped values leave no markup in the wikitext, and that SMW reads back the
+// literal, for each escaping strategy meant to keep values
+func TestEscapeWikiValueCorpus(t *testing.T) {
+	literals := readValueCorpus(t, "testdata/value-corpus.txt")
+	for _, escaping := range []string{ValueEscapingEntities, ValueEscapingNowiki} {
+		escapings := map[string]string{smwTypeText: escaping}
+		for _, separator := range []string{"", ",", ";"} {
+			for _, literal := range literals {
+				escaped := escapeWikiValue(literal, smwTypeText, escapings, separator)
+				if markup := wikiMarkupIn(escaped, separator); markup != "" {
+					t.Errorf("Markup %q left in value %q escaped with %s (separator %q): %q", markup, literal, escaping, separator, escaped)
+				}
+				if stored := storedSMWValue(escaped); stored != literal {
+					t.Errorf("Value %q escaped with %s (separator %q) is stored as %q", literal, escaping, separator, stored)
+				}
+			}
+		}
+	}
+}
+
+func readValueCorpus(t *testing.T, fileName string) []string {
+	fh, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	literals := []string{}
+	lines := bufio.NewScanner(fh)
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		literal, err := strconv.Unquote(line)
+		if err != nil {
+			t.Fatalf("Invalid line in %s: %s", fileName, line)
+		}
+		literals = append(literals, literal)
+	}
+	return literals
+}
+
+var nowikiRegex = regexp.MustCompile(`(?s)<nowiki>(.*?)</nowiki>`)
+
+// wikiMarkupIn returns the first character or string in the escaped value
+// that MediaWiki would read as markup (outside of nowiki tags), or "" if there
+// is none.
+func wikiMarkupIn(escaped string, separator string) string {
+	outside := nowikiRegex.ReplaceAllString(escaped, "")
+	for _, markup := range []string{"[", "]", "{", "}", "|", "<", ">", "\n", "\r"} {
+		if strings.Contains(outside, markup) {
+			return markup
+		}
+	}
+	if separator != "" && strings.Contains(nowikiRegex.ReplaceAllString(escaped, "nowiki"), separator) {
+		return separator
+	}
+	if magicWord := magicWordRegex.FindString(outside); magicWord != "" {
+		return magicWord
+	}
+	if strings.Contains(outside, "::") {
+		return "::"
+	}
+	return ""
+}
+
+// storedSMWValue models how MediaWiki and SMW read an escaped value: nowiki
+// tags are removed, keeping their content, and character references (only
+// with the ending semicolon) are decoded.
+func storedSMWValue(escaped string) string {
+	unstripped := nowikiRegex.ReplaceAllString(escaped, "$1")
+	return htmlEntityRegex.ReplaceAllStringFunc(unstripped, html.UnescapeString)
+}
+
+func TestSanitizeXMLText(t *testing.T) {
+	tests := map[string]string{
+		"plain text":            "plain text",
+		"tab\tand\nnewline\r.":  "tab\tand\nnewline\r.",
+		"bell\x07 and nul\x00":  "bell\uFFFD and nul\uFFFD",
+		"invalid \xff\xfe utf8": "invalid \uFFFD\uFFFD utf8",
+		"noncharacter \uFFFF":   "noncharacter \uFFFD",
+		"R&D <x> \"q\" ]]>":     "R&D <x> \"q\" ]]>",
+	}
+	for value, expected := range tests {
+		if sanitized := sanitizeXMLText(value); sanitized != expected {
+			t.Errorf("Wrong sanitizing of %q (Expected %q, got %q)", value, expected, sanitized)
+		}
+	}
+}
+
+// importedDump holds what MediaWiki reads from an export document.
+type importedDump struct {
+	Pages []struct {
+		Title    string `xml:"title"`
+		Redirect struct {
+			Title string `xml:"title,attr"`
+		} `xml:"redirect"`
+		Text struct {
+			Bytes int    `xml:"bytes,attr"`
+			SHA1  string `xml:"sha1,attr"`
+			Text  string `xml:",chardata"`
+		} `xml:"revision>text"`
+	} `xml:"page"`
+}
+
+// TestMWXMLCreatorRoundTrip tests that titles and wikitext with characters
+// special to XML or wikitext are read back from the XML as written, with only
+// the characters XML can not hold replaced
+func TestMWXMLCreatorRoundTrip(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	values := []string{
+		"R&D",
+		"Fish &amp; chips",
+		"]]> ends CDATA <![CDATA[",
+		"<script>alert('x')</script>",
+		"bell\x07 and nul\x00",
+		"invalid \xff utf8",
+		"quotes \" and ' apostrophes",
+		"line one\nline two",
+	}
+	expectedValues := []string{
+		"R&D",
+		"Fish &amp;amp; chips",
+		"&#93;&#93;&gt; ends CDATA &lt;!&#91;CDATA&#91;",
+		"&lt;script&gt;alert('x')&lt;/script&gt;",
+		"bell\uFFFD and nul\uFFFD",
+		"invalid \uFFFD utf8",
+		"quotes \" and ' apostrophes",
+		"line one&#10;line two",
+	}
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+	mxc.UseTemplates = false
+	go func() {
+		defer close(mxc.InWikiPage)
+		page := NewWikiPage("R&D \"Lab\" <1>\x01", []*Fact{}, []*Category{}, nil, URITypeUndefined)
+		for _, value := range values {
+			page.AddFactUnique(NewFact("Has value", value))
+		}
+		page.Redirects = []string{"Research & development"}
+		mxc.InWikiPage <- page
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	dump := &importedDump{}
+	if err := xml.Unmarshal([]byte(output), dump); err != nil {
+		t.Fatalf("Output is not well-formed XML: %s\n%s", err.Error(), output)
+	}
+	if len(dump.Pages) != 2 {
+		t.Fatalf("Wrong number of pages (Expected 2, got %d)", len(dump.Pages))
+	}
+
+	page := dump.Pages[0]
+	if page.Title != "R&D \"Lab\" <1>\uFFFD" {
+		t.Errorf("Wrong title read back: %q", page.Title)
+	}
+	expectedText := ""
+	for _, value := range expectedValues {
+		expectedText += "[[Has value::" + value + "]]\n"
+	}
+	if page.Text.Text != expectedText {
+		t.Errorf("Wrong text read back (Expected %q, got %q)", expectedText, page.Text.Text)
+	}
+	for _, page := range dump.Pages {
+		if page.Text.Bytes != len(page.Text.Text) {
+			t.Errorf("Wrong size of text of %s (Expected %d, got %d)", page.Title, len(page.Text.Text), page.Text.Bytes)
+		}
+		if page.Text.SHA1 != mwSHA1(page.Text.Text) {
+			t.Errorf("Wrong hash of text of %s", page.Title)
+		}
+	}
+
+	redirect := dump.Pages[1]
+	if redirect.Title != "Research & development" || redirect.Redirect.Title != page.Title {
+		t.Errorf("Wrong redirect read back: %q to %q", redirect.Title, redirect.Redirect.Title)
+	}
+	if !strings.Contains(redirect.Text.Text, "#REDIRECT [["+page.Title+"]]") {
+		t.Errorf("Wrong redirect text read back: %q", redirect.Text.Text)
+	}
+}
+
+func TestEscapeWikiValueStrategies(t *testing.T) {
+	escapings := DefaultConfig().ValueEscaping
+	tests := []struct {
+		value     string
+		smwType   string
+		separator string
+		expected  string
+	}{
+		{"C(=O)[O-]", smwTypeText, "", "C(=O)&#91;O-&#93;"},
+		{"a, b", smwTypeText, ",", "a&#44; b"},
+		{"a; b", smwTypeText, ";", "<nowiki>a; b</nowiki>"},
+		{"a|b", smwTypeText, ";", "<nowiki>a|b</nowiki>"},
+		{"plain", smwTypeText, ";", "plain"},
+		{"Alice", smwTypePage, ",", "Alice"},
+		{"A [draft]; B", smwTypePage, ";", "A (draft)  B"},
+		{"a|b", "", "", "a&#124;b"},
+	}
+	for _, tt := range tests {
+		if escaped := escapeWikiValue(tt.value, tt.smwType, escapings, tt.separator); escaped != tt.expected {
+			t.Errorf("Wrong escaping of %s value %q (Expected %q, got %q)", tt.smwType, tt.value, tt.expected, escaped)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package components
 
 import (
 	"bufio"
-	"log"
+	"fmt"
 
 	"github.com/flowbase/flowbase"
 	"github.com/spf13/afero"
@@ -14,10 +14,13 @@ import (
 
 // FileReader is a process that reads files, based on file names it receives on the
 // FileReader.InFileName port / channel, and writes out the output line by line
-// as strings on the FileReader.OutLine port / channel.
+// as strings on the FileReader.OutLine port / channel. Files compressed with
+// gzip, bzip2 or xz are decompressed on the fly. Files that can not be read
+// are reported as *ConversionError's on the OutError port.
 type FileReader struct {
 	InFileName chan string
 	OutLine    chan string
+	OutError   chan *ConversionError
 	fs         afero.Fs
 }
 
@@ -33,6 +36,7 @@ func NewFileReader(fileSystem afero.Fs) *FileReader {
 	return &FileReader{
 		InFileName: make(chan string, BUFSIZE),
 		OutLine:    make(chan string, BUFSIZE),
+		OutError:   make(chan *ConversionError, BUFSIZE),
 		fs:         fileSystem,
 	}
 }
@@ -42,22 +46,36 @@ func NewFileReader(fileSystem afero.Fs) *FileReader {
 // in a separate go-routine.
 func (p *FileReader) Run() {
 	defer close(p.OutLine)
+	defer close(p.OutError)
 
 	flowbase.Debug.Println("Starting loop")
 	for fileName := range p.InFileName {
 		flowbase.Debug.Printf("Starting processing file %s\n", fileName)
-		fh, err := p.fs.Open(fileName)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer fh.Close()
-
-		sc := bufio.NewScanner(fh)
-		for sc.Scan() {
-			if err := sc.Err(); err != nil {
-				log.Fatal(err)
-			}
-			p.OutLine <- sc.Text()
-		}
+		p.readFile(fileName)
+	}
+}
+
+func (p *FileReader) readFile(fileName string) {
+	fh, err := p.fs.Open(fileName)
+	if err != nil {
+		p.OutError <- NewConversionError(fileName, 0, "", err)
+		return
+	}
+	defer fh.Close()
+
+	r, err := newDecompressingReader(fh)
+	if err != nil {
+		p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("could not decompress file: %s", err.Error()))
+		return
+	}
+
+	lineNo := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lineNo++
+		p.OutLine <- sc.Text()
+	}
+	if err := sc.Err(); err != nil {
+		p.OutError <- NewConversionError(fileName, lineNo+1, "", err)
 	}
 }
@@ -0,0 +1,104 @@
+package components
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	str "strings"
+
+	"github.com/spf13/afero"
+)
+
+// ExpandInputFileNames expands a list of input paths, as given on the
+// commandline, into a list of file names to read. Each path can be a comma
+// separated list of paths, and each of those can be a file name, a glob
+// pattern, or a directory, which is searched recursively for files with a
+// known RDF file extension. The file name StdioFileName, denoting standard
+// input, is passed through as is. File names are returned in the order given, with
+// the results of each glob or directory sorted, and with duplicates removed.
+func ExpandInputFileNames(fs afero.Fs, paths []string) ([]string, error) {
+	fileNames := []string{}
+	seen := make(map[string]bool)
+	add := func(fileName string) {
+		if !seen[fileName] {
+			seen[fileName] = true
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	for _, pathList := range paths {
+		for _, path := range str.Split(pathList, ",") {
+			path = str.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			if path == StdioFileName {
+				add(path)
+				continue
+			}
+
+			matches := []string{path}
+			if str.ContainsAny(path, "*?[") {
+				var err error
+				matches, err = afero.Glob(fs, path)
+				if err != nil {
+					return nil, fmt.Errorf("invalid glob pattern %s: %s", path, err.Error())
+				}
+				if len(matches) == 0 {
+					return nil, fmt.Errorf("no files matching %s", path)
+				}
+				sort.Strings(matches)
+			}
+
+			for _, match := range matches {
+				info, err := fs.Stat(match)
+				if err != nil {
+					return nil, err
+				}
+				if !info.IsDir() {
+					add(match)
+					continue
+				}
+				dirFileNames, err := findRDFFiles(fs, match)
+				if err != nil {
+					return nil, err
+				}
+				if len(dirFileNames) == 0 {
+					return nil, fmt.Errorf("no RDF files found in directory %s", match)
+				}
+				for _, fileName := range dirFileNames {
+					add(fileName)
+				}
+			}
+		}
+	}
+	return fileNames, nil
+}
+
+// findRDFFiles returns the sorted names of all files with a known RDF file
+// extension in dir and its subdirectories.
+func findRDFFiles(fs afero.Fs, dir string) ([]string, error) {
+	fileNames := []string{}
+	err := afero.Walk(fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && hasRDFFileExtension(path) {
+			fileNames = append(fileNames, path)
+		}
+		return nil
+	})
+	sort.Strings(fileNames)
+	return fileNames, err
+}
+
+// hasRDFFileExtension tells whether a file found in a directory should be read
+// as RDF. Files ending in .xml are skipped, as they are just as likely to be
+// MediaWiki XML dumps written by earlier runs. Compression extensions are
+// ignored, so that "data.nt.gz" counts as an RDF file.
+func hasRDFFileExtension(fileName string) bool {
+	ext := str.ToLower(filepath.Ext(TrimCompressionExt(fileName)))
+	_, ok := rdfFormatExtensions[ext]
+	return ok && ext != ".xml"
+}
@@ -0,0 +1,53 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// TestExpandInputFileNames tests expansion of comma lists, globs and directories
+func TestExpandInputFileNames(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, fileName := range []string{
+		"onto.owl",
+		"data/a.nt",
+		"data/b.nt",
+		"data/sub/c.ttl",
+		"data/sub/notes.txt",
+		"data/sub/old_output.xml",
+		"more/d.nt",
+		"more/e.nt",
+	} {
+		afero.WriteFile(fs, fileName, []byte(""), 0644)
+	}
+
+	fileNames, err := ExpandInputFileNames(fs, []string{"onto.owl", "data", "more/*.nt,onto.owl"})
+	if err != nil {
+		t.Fatal("Could not expand input file names: ", err.Error())
+	}
+
+	expected := []string{
+		"onto.owl",
+		"data/a.nt",
+		"data/b.nt",
+		"data/sub/c.ttl",
+		"more/d.nt",
+		"more/e.nt",
+	}
+	if len(fileNames) != len(expected) {
+		t.Fatalf("Wrong number of file names: %v (expected %v)", fileNames, expected)
+	}
+	for i, fileName := range expected {
+		if fileNames[i] != fileName {
+			t.Errorf("Wrong file name at position %d: %s (expected %s)", i, fileNames[i], fileName)
+		}
+	}
+
+	if _, err := ExpandInputFileNames(fs, []string{"missing.nt"}); err == nil {
+		t.Error("No error for missing input file")
+	}
+	if _, err := ExpandInputFileNames(fs, []string{"nomatch/*.nt"}); err == nil {
+		t.Error("No error for glob without matches")
+	}
+}
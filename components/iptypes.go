@@ -1,8 +1,6 @@
 package components
 
 import (
-	str "strings"
-
 	"github.com/knakk/rdf"
 )
 
@@ -34,6 +32,17 @@ type WikiPage struct {
 	Facts            []*Fact
 	Categories       []*Category
 	SpecificCategory *Category
+	// Domains holds the categories a property belongs to, from its
+	// rdfs:domain, and is only used for property pages
+	Domains []*Category
+	// Subobjects holds the blank nodes inlined into the page
+	Subobjects []*Subobject
+	// Redirects holds alternative titles of the page, to create redirect
+	// pages for
+	Redirects []string
+	// Description holds the description of a class, and is only used for
+	// category pages
+	Description string
 }
 
 func NewWikiPage(title string, facts []*Fact, categories []*Category, specificCategory *Category, pageType int) *WikiPage {
@@ -53,7 +62,7 @@ func (p *WikiPage) AddFact(fact *Fact) {
 func (p *WikiPage) AddFactUnique(fact *Fact) {
 	factExists := false
 	for _, existingFact := range p.Facts {
-		if fact.Property == existingFact.Property && fact.Value == existingFact.Value {
+		if fact.Property == existingFact.Property && fact.Value == existingFact.Value && fact.ListIndex == existingFact.ListIndex {
 			factExists = true
 			break
 		}
@@ -63,6 +72,29 @@ func (p *WikiPage) AddFactUnique(fact *Fact) {
 	}
 }
 
+func (p *WikiPage) AddDomainUnique(domain *Category) {
+	for _, existingDomain := range p.Domains {
+		if domain.Name == existingDomain.Name {
+			return
+		}
+	}
+	p.Domains = append(p.Domains, domain)
+}
+
+// AddRedirectUnique adds the alternative title title, unless it is the title
+// of the page, or already added.
+func (p *WikiPage) AddRedirectUnique(title string) {
+	if title == p.Title {
+		return
+	}
+	for _, existingTitle := range p.Redirects {
+		if title == existingTitle {
+			return
+		}
+	}
+	p.Redirects = append(p.Redirects, title)
+}
+
 func (p *WikiPage) AddCategory(category *Category) {
 	p.Categories = append(p.Categories, category)
 }
@@ -87,6 +119,12 @@ func (p *WikiPage) AddCategoryUnique(category *Category) {
 type Fact struct {
 	Property string
 	Value    string
+	// ListIndex is the position of the value in an ordered list of values,
+	// starting at 1, or 0 for values that are not in a list
+	ListIndex int
+	// DataType is the SMW type of the value, deciding how it is escaped in
+	// wikitext, or empty for text
+	DataType string
 }
 
 func NewFact(property string, value string) *Fact {
@@ -96,18 +134,57 @@ func NewFact(property string, value string) *Fact {
 	}
 }
 
-func (f *Fact) asWikiFact() string {
-	return "[[" + f.Property + "::" + f.escapeWikiChars(f.Value) + "]]\n"
+// asWikiFact returns the fact as an annotation, with the value escaped as
+// set for its type in escapings.
+func (f *Fact) asWikiFact(escapings map[string]string) string {
+	return "[[" + f.Property + "::" + f.escapedValue(escapings, "") + "]]\n"
+}
+
+// escapedValue returns the value escaped for wikitext, as set for its type in
+// escapings, with separator escaped as well if not empty.
+func (f *Fact) escapedValue(escapings map[string]string, separator string) string {
+	dataType := f.DataType
+	if dataType == "" {
+		dataType = smwTypeText
+	}
+	return escapeWikiValue(f.Value, dataType, escapings, separator)
+}
+
+// ------------------------------------------------------------
+// Helper type: Subobject
+// ------------------------------------------------------------
+
+// Subobject is a named group of facts within a page, written as an SMW
+// {{#subobject:}} parser function call.
+type Subobject struct {
+	Name  string
+	Facts []*Fact
 }
 
-func (f *Fact) escapeWikiChars(inStr string) string {
-	outStr := str.Replace(inStr, "[", "(", -1)
-	outStr = str.Replace(outStr, "]", ")", -1)
-	outStr = str.Replace(outStr, "|", ",", -1)
-	outStr = str.Replace(outStr, "=", "-", -1)
-	outStr = str.Replace(outStr, "<", "&lt;", -1)
-	outStr = str.Replace(outStr, ">", "&gt;", -1)
-	return outStr
+func NewSubobject(name string) *Subobject {
+	return &Subobject{
+		Name:  name,
+		Facts: []*Fact{},
+	}
+}
+
+func (s *Subobject) AddFactUnique(fact *Fact) {
+	for _, existingFact := range s.Facts {
+		if fact.Property == existingFact.Property && fact.Value == existingFact.Value && fact.ListIndex == existingFact.ListIndex {
+			return
+		}
+	}
+	s.Facts = append(s.Facts, fact)
+}
+
+// asWikiString returns the subobject as a #subobject call, with the values
+// escaped as set for their types in escapings.
+func (s *Subobject) asWikiString(escapings map[string]string) string {
+	wikiStr := "{{#subobject:" + s.Name + "\n"
+	for _, fact := range s.Facts {
+		wikiStr += "|" + fact.Property + "=" + fact.escapedValue(escapings, "") + "\n"
+	}
+	return wikiStr + "}}\n"
 }
 
 // ------------------------------------------------------------
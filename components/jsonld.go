@@ -0,0 +1,26 @@
+package components
+
+import (
+	"io"
+	str "strings"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// jsonLDToNQuads converts the JSON-LD document read from r to N-Quads, for
+// the N-Quads decoder. Unlike the other formats, a JSON-LD document can only
+// be converted once read in full, since the context that gives the meaning
+// of its keys may come last. Remote contexts are loaded over HTTP.
+func jsonLDToNQuads(r io.Reader) (io.Reader, error) {
+	doc, err := ld.DocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	opts := ld.NewJsonLdOptions("")
+	opts.Format = "application/n-quads"
+	nquads, err := ld.NewJsonLdProcessor().ToRDF(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return str.NewReader(nquads.(string)), nil
+}
@@ -0,0 +1,64 @@
+package components
+
+import (
+	"regexp"
+	str "strings"
+
+	"github.com/knakk/rdf"
+)
+
+// Modes for how language-tagged literals are written
+const (
+	// LanguageModeText writes language-tagged literals as plain text,
+	// dropping the language tag
+	LanguageModeText = "text"
+	// LanguageModeMonolingual writes language-tagged literals as SMW
+	// "Monolingual text" values, in the form text@lang
+	LanguageModeMonolingual = "monolingual"
+	// LanguageModeProperties writes language-tagged literals as values of a
+	// separate property per language, such as "Label (en)"
+	LanguageModeProperties = "properties"
+)
+
+var languageModes = []string{LanguageModeText, LanguageModeMonolingual, LanguageModeProperties}
+
+const smwTypeMonolingual = "Monolingual text"
+
+var languageTagRegex = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+// literalLang returns the language tag of obj, or "" if it is not a
+// language-tagged literal.
+func literalLang(obj rdf.Object) string {
+	if lit, ok := obj.(rdf.Literal); ok {
+		return lit.Lang()
+	}
+	return ""
+}
+
+// languageRank returns how well the language tag lang matches the list of
+// preferred languages, where lower is better: an exact match of a preferred
+// language ranks first (in list order), then a more specific tag of it (such
+// as "en-GB" for "en"), then literals without a language tag, and last any
+// other language.
+func languageRank(lang string, preferred []string) int {
+	lang = str.ToLower(lang)
+	for i, pref := range preferred {
+		pref = str.ToLower(pref)
+		if lang == pref {
+			return 2 * i
+		}
+		if str.HasPrefix(lang, pref+"-") {
+			return 2*i + 1
+		}
+	}
+	if lang == "" {
+		return 2 * len(preferred)
+	}
+	return 2*len(preferred) + 1
+}
+
+// languageProperty returns the name of the per-language variant of a
+// property, as used with LanguageModeProperties.
+func languageProperty(property string, lang string) string {
+	return property + " (" + lang + ")"
+}
@@ -0,0 +1,74 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/flowbase/flowbase"
+)
+
+func TestLanguageRank(t *testing.T) {
+	preferred := []string{"sv", "en"}
+	ordered := []string{"sv", "sv-FI", "en", "EN-gb", "", "de"}
+	for i := 1; i < len(ordered); i++ {
+		if languageRank(ordered[i-1], preferred) >= languageRank(ordered[i], preferred) {
+			t.Errorf("Language %q should rank before %q", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+func TestLanguageModes(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/sthlm> <http://www.w3.org/2000/01/rdf-schema#label> "Stockholm City"@en .
+<http://example.org/sthlm> <http://www.w3.org/2000/01/rdf-schema#label> "Stockholm"@sv .
+<http://example.org/sthlm> <http://example.org/motto> "Capital of Scandinavia"@en-GB .
+<http://example.org/sthlm> <http://example.org/motto> "Skandinaviens huvudstad"@sv .
+`
+	tests := map[string]struct {
+		expectedFacts []*Fact
+		expectedTypes map[string]string
+	}{
+		LanguageModeText: {
+			[]*Fact{{Property: "Motto", Value: "Capital of Scandinavia", DataType: "Text"}, {Property: "Motto", Value: "Skandinaviens huvudstad", DataType: "Text"}},
+			map[string]string{"Property:Motto": "Text"},
+		},
+		LanguageModeMonolingual: {
+			[]*Fact{{Property: "Motto", Value: "Capital of Scandinavia@en-GB", DataType: "Monolingual text"}, {Property: "Motto", Value: "Skandinaviens huvudstad@sv", DataType: "Monolingual text"}},
+			map[string]string{"Property:Motto": "Monolingual text"},
+		},
+		LanguageModeProperties: {
+			[]*Fact{{Property: "Motto (en-GB)", Value: "Capital of Scandinavia", DataType: "Text"}, {Property: "Motto (sv)", Value: "Skandinaviens huvudstad", DataType: "Text"}},
+			map[string]string{"Property:Motto (en-GB)": "Text", "Property:Motto (sv)": "Text"},
+		},
+	}
+	for mode, tt := range tests {
+		conf := DefaultConfig()
+		conf.Languages = []string{"sv", "en"}
+		conf.LanguageMode = mode
+		pages := map[string]*WikiPage{}
+		for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+			pages[page.Title] = page
+		}
+
+		page := pages["Stockholm"]
+		if page == nil {
+			t.Errorf("Mode %s: page not titled by the preferred language, got pages: %v", mode, pages)
+			continue
+		}
+		for _, expected := range tt.expectedFacts {
+			found := false
+			for _, fact := range page.Facts {
+				found = found || *fact == *expected
+			}
+			if !found {
+				t.Errorf("Mode %s: fact %v missing, got: %v", mode, expected, page.Facts)
+			}
+		}
+		for title, smwType := range tt.expectedTypes {
+			if pages[title] == nil || len(pages[title].Facts) == 0 || *pages[title].Facts[len(pages[title].Facts)-1] != *NewFact("Has type", smwType) {
+				t.Errorf("Mode %s: page %s missing, or without Has type %s", mode, title, smwType)
+			}
+		}
+	}
+}
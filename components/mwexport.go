@@ -0,0 +1,171 @@
+package components
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"math/big"
+	"strconv"
+	str "strings"
+)
+
+// The version of the MediaWiki XML export format written by MWXMLCreator,
+// with its namespace and schema
+const (
+	mwExportVersion        = "0.11"
+	mwExportNamespace      = "http://www.mediawiki.org/xml/export-0.11/"
+	mwExportSchemaLocation = "http://www.mediawiki.org/xml/export-0.11.xsd"
+	mwExportGenerator      = "RDF2SMW"
+)
+
+// mwExportHeader is the start tag of the root element of an export document.
+const mwExportHeader = `<mediawiki xmlns="` + mwExportNamespace + `"` +
+	` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"` +
+	` xsi:schemaLocation="` + mwExportNamespace + ` ` + mwExportSchemaLocation + `"` +
+	` version="` + mwExportVersion + `" xml:lang="en">` + "\n"
+
+// mwExportFooter is the end tag of the root element of an export document.
+const mwExportFooter = "</mediawiki>\n"
+
+// Case rules of titles, in the siteinfo element
+const (
+	mwCaseFirstLetter   = "first-letter"
+	mwCaseCaseSensitive = "case-sensitive"
+)
+
+// mwSiteInfo is the siteinfo element of an export document, describing the
+// wiki.
+type mwSiteInfo struct {
+	XMLName    xml.Name      `xml:"siteinfo"`
+	Sitename   string        `xml:"sitename,omitempty"`
+	DBName     string        `xml:"dbname,omitempty"`
+	Base       string        `xml:"base,omitempty"`
+	Generator  string        `xml:"generator"`
+	Case       string        `xml:"case"`
+	Namespaces []mwNamespace `xml:"namespaces>namespace"`
+}
+
+type mwNamespace struct {
+	Key  int    `xml:"key,attr"`
+	Case string `xml:"case,attr"`
+	Name string `xml:",chardata"`
+}
+
+// mwPage is a page element of an export document, with a single revision.
+type mwPage struct {
+	XMLName  xml.Name    `xml:"page"`
+	Title    string      `xml:"title"`
+	NS       int         `xml:"ns"`
+	ID       int         `xml:"id"`
+	Redirect *mwRedirect `xml:"redirect"`
+	Revision mwRevision  `xml:"revision"`
+}
+
+type mwRedirect struct {
+	Title string `xml:"title,attr"`
+}
+
+type mwRevision struct {
+	ID          int           `xml:"id"`
+	Timestamp   string        `xml:"timestamp"`
+	Contributor mwContributor `xml:"contributor"`
+	Comment     string        `xml:"comment"`
+	Origin      int           `xml:"origin"`
+	Model       string        `xml:"model"`
+	Format      string        `xml:"format"`
+	Text        mwText        `xml:"text"`
+	SHA1        string        `xml:"sha1"`
+}
+
+type mwContributor struct {
+	IP string `xml:"ip"`
+}
+
+// mwText is the text element of a revision, holding the wikitext.
+type mwText struct {
+	Bytes int
+	SHA1  string
+	Text  string
+}
+
+// MarshalXML writes the text element, with the line breaks of the text kept
+// as they are (rather than as character references, as for chardata fields).
+func (t mwText) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "bytes"}, Value: strconv.Itoa(t.Bytes)},
+		xml.Attr{Name: xml.Name{Local: "sha1"}, Value: t.SHA1},
+		xml.Attr{Name: xml.Name{Space: "http://www.w3.org/XML/1998/namespace", Local: "space"}, Value: "preserve"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(t.Text)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// newMWSiteInfo returns the siteinfo element for the wiki described by conf.
+func newMWSiteInfo(conf *Config) *mwSiteInfo {
+	caseRule := mwCaseFirstLetter
+	if !conf.CapitalLinks {
+		caseRule = mwCaseCaseSensitive
+	}
+	siteInfo := &mwSiteInfo{
+		Sitename:  sanitizeXMLText(conf.SiteInfo.Sitename),
+		DBName:    sanitizeXMLText(conf.SiteInfo.DBName),
+		Base:      sanitizeXMLText(conf.SiteInfo.Base),
+		Generator: mwExportGenerator,
+		Case:      caseRule,
+	}
+	for _, ns := range conf.SiteInfo.Namespaces {
+		siteInfo.Namespaces = append(siteInfo.Namespaces, mwNamespace{Key: ns.Key, Case: caseRule, Name: sanitizeXMLText(ns.Name)})
+	}
+	return siteInfo
+}
+
+// newMWPage returns the page element for a page with a single revision, with
+// the text wikiText. redirectTarget is the title of the page redirected to,
+// if the page is a redirect. Characters that XML can not hold are replaced
+// before the size and hash of the text are taken, so that these match the
+// text written.
+func newMWPage(id int, title string, ns int, timestamp string, wikiText string, redirectTarget string) *mwPage {
+	title = sanitizeXMLText(title)
+	wikiText = sanitizeXMLText(wikiText)
+	textSHA1 := mwSHA1(wikiText)
+	page := &mwPage{
+		Title: title,
+		NS:    ns,
+		ID:    id,
+		Revision: mwRevision{
+			ID:          id,
+			Timestamp:   timestamp,
+			Contributor: mwContributor{IP: "127.0.0.1"},
+			Comment:     "Page created by RDF2SMW commandline tool",
+			Origin:      id,
+			Model:       "wikitext",
+			Format:      "text/x-wiki",
+			Text:        mwText{Bytes: len(wikiText), SHA1: textSHA1, Text: wikiText},
+			SHA1:        textSHA1,
+		},
+	}
+	if redirectTarget != "" {
+		page.Redirect = &mwRedirect{Title: sanitizeXMLText(redirectTarget)}
+	}
+	return page
+}
+
+// marshalMWElement returns the XML of an element of an export document,
+// indented to go inside the root element.
+func marshalMWElement(element interface{}) string {
+	// Marshalling only fails for types that can not be marshalled, which the
+	// export element types do not contain
+	data, _ := xml.MarshalIndent(element, "  ", "  ")
+	return string(data) + "\n"
+}
+
+// mwSHA1 returns the SHA-1 hash of text the way MediaWiki writes it: in base
+// 36, padded to 31 digits.
+func mwSHA1(text string) string {
+	hash := sha1.Sum([]byte(text))
+	digits := new(big.Int).SetBytes(hash[:]).Text(36)
+	return str.Repeat("0", 31-len(digits)) + digits
+}
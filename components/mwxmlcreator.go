@@ -2,46 +2,71 @@ package components
 
 import (
 	"fmt"
+	"sort"
 	str "strings"
 	"time"
 )
 
+// MWXMLCreator is a process that converts *WikiPage's into MediaWiki XML,
+// written as one XML document each for templates, properties and other pages,
+// on the OutTemplates, OutProperties and OutPages ports respectively. If
+// Interleave is set, all pages are instead written to the OutPages port, as
+// one single XML document. If SeparateCategories is set (and Interleave is
+// not), category pages are written as a document of their own on the
+// OutCategories port, rather than with the other pages.
+//
+// The documents follow the MediaWiki export format version 0.11, each
+// starting with a siteinfo element describing the wiki as configured in
+// Config.SiteInfo. Pages are numbered across all documents, and each has a
+// single revision, numbered as the page.
+//
+// Properties are added to the templates of the categories their pages have
+// as Domains (from rdfs:domain), in addition to the templates of the pages
+// using them, so that templates exist even for classes without instances.
+//
+// Category pages (of classes) get the description of the class, links to the
+// template and form of the category, and a listing of the members of the
+// category, if CategoryPages is enabled in the configuration. Their facts are
+// then written as annotations, not template calls, and a template is created
+// for each category page, even when no page uses it.
+//
+// The alternative titles in the Redirects of pages get redirect pages to the
+// pages, written after the other pages in the same namespace. Titles that are
+// also the titles of pages get no redirect pages. Alternative titles of
+// several pages get disambiguation pages listing them instead, in the main
+// namespace, if Redirects.DisambiguationPages is enabled in the
+// configuration.
+//
+// Revisions are stamped with the current time, unless Timestamp is set, in
+// which case that is used for all revisions (for reproducible output).
 type MWXMLCreator struct {
-	InWikiPage    chan *WikiPage
-	OutTemplates  chan string
-	OutProperties chan string
-	OutPages      chan string
-	UseTemplates  bool
+	InWikiPage         chan *WikiPage
+	OutTemplates       chan string
+	OutProperties      chan string
+	OutCategories      chan string
+	OutPages           chan string
+	UseTemplates       bool
+	Interleave         bool
+	SeparateCategories bool
+	Timestamp          time.Time
+	conf               *Config
+	lastPageID         int
 }
 
-func NewMWXMLCreator(useTemplates bool) *MWXMLCreator {
+// NewMWXMLCreator returns an initialized MWXMLCreator, taking its template
+// options from the mapping configuration conf.
+func NewMWXMLCreator(conf *Config) *MWXMLCreator {
 	return &MWXMLCreator{
 		InWikiPage:    make(chan *WikiPage, BUFSIZE),
 		OutTemplates:  make(chan string, BUFSIZE),
 		OutProperties: make(chan string, BUFSIZE),
+		OutCategories: make(chan string, BUFSIZE),
 		OutPages:      make(chan string, BUFSIZE),
-		UseTemplates:  useTemplates,
+		UseTemplates:  conf.Templates.Enabled,
+		conf:          conf,
 	}
 }
 
-const wikiXmlTpl = `
-	<page>
-		<title>%s</title>
-		<ns>%d</ns>
-		<revision>
-			<timestamp>%s</timestamp>
-			<contributor>
-				<ip>127.0.0.1</ip>
-			</contributor>
-			<comment>Page created by RDF2SMW commandline tool</comment>
-			<model>wikitext</model>
-			<format>text/x-wiki</format>
-			<text xml:space="preserve">
-%s</text>
-		</revision>
-	</page>
-`
-
 var pageTypeToMWNamespace = map[int]int{
 	URITypeClass:     14,
 	URITypeTemplate:  10,
@@ -49,24 +74,82 @@ var pageTypeToMWNamespace = map[int]int{
 	URITypeUndefined: 0,
 }
 
+// timestamp returns the revision timestamp to use for the next page.
+func (p *MWXMLCreator) timestamp() string {
+	ts := p.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return ts.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// pageXML returns the XML of a page, numbered after the pages before it.
+// redirectTarget is the title of the page redirected to, for redirect pages.
+func (p *MWXMLCreator) pageXML(title string, ns int, wikiText string, redirectTarget string) string {
+	p.lastPageID++
+	return marshalMWElement(newMWPage(p.lastPageID, title, ns, p.timestamp(), wikiText, redirectTarget))
+}
+
 func (p *MWXMLCreator) Run() {
 	tplPropertyIdx := make(map[string]map[string]int)
+	pageTitles := make(map[string]bool)
+	redirectTargets := make(map[string][]string)
+	sep := p.conf.Templates.ValueSeparator
+	catParam := p.conf.Templates.CategoriesParam
 
 	defer close(p.OutTemplates)
 	defer close(p.OutProperties)
+	defer close(p.OutCategories)
 	defer close(p.OutPages)
 
-	p.OutPages <- "<mediawiki>\n"
-	p.OutProperties <- "<mediawiki>\n"
+	outTemplates, outProperties, outCategories := p.OutTemplates, p.OutProperties, p.OutPages
+	docOuts := []chan string{p.OutPages, p.OutProperties, p.OutTemplates}
+	if p.Interleave {
+		outTemplates, outProperties = p.OutPages, p.OutPages
+		docOuts = []chan string{p.OutPages}
+	} else if p.SeparateCategories {
+		outCategories = p.OutCategories
+		docOuts = append(docOuts, p.OutCategories)
+	}
+
+	siteInfoXML := marshalMWElement(newMWSiteInfo(p.conf))
+	for _, docOut := range docOuts {
+		docOut <- mwExportHeader + siteInfoXML
+	}
 
 	for page := range p.InWikiPage {
+		pageTitles[page.Title] = true
+		for _, redirect := range page.Redirects {
+			if !containsString(redirectTargets[redirect], page.Title) {
+				redirectTargets[redirect] = append(redirectTargets[redirect], page.Title)
+			}
+		}
+
+		if p.UseTemplates && page.Type == URITypePredicate {
+			for _, domain := range page.Domains {
+				templateTitle := "Template:" + domain.Name
+				if tplPropertyIdx[templateTitle] == nil {
+					tplPropertyIdx[templateTitle] = make(map[string]int)
+				}
+				tplPropertyIdx[templateTitle][str.TrimPrefix(page.Title, "Property:")] = 1
+			}
+		}
 
 		wikiText := ""
 
-		if p.UseTemplates && len(page.Categories) > 0 { // We need at least one category, as to name the (to-be) template
+		if page.Type == URITypeClass && p.conf.CategoryPages.Enabled {
+
+			wikiText = p.categoryPageText(page)
+
+			// Make sure template page exists, as it is linked to
+			templateTitle := "Template:" + str.TrimPrefix(page.Title, "Category:")
+			if p.UseTemplates && tplPropertyIdx[templateTitle] == nil {
+				tplPropertyIdx[templateTitle] = make(map[string]int)
+			}
+		} else if p.UseTemplates && len(page.Categories) > 0 { // We need at least one category, as to name the (to-be) template
 
 			var templateName string
-			if page.SpecificCategory.Name != "" {
+			if page.SpecificCategory != nil && page.SpecificCategory.Name != "" {
 				templateName = page.SpecificCategory.Name
 			} else {
 				// Pick last item (biggest chance to be pretty specific?)
@@ -87,9 +170,9 @@ func (p *MWXMLCreator) Run() {
 			for _, fact := range page.Facts {
 				// Write facts to template call on current page
 
-				val := escapeWikiChars(fact.Value)
+				val := fact.escapedValue(p.conf.ValueEscaping, sep)
 				if fact.Property == lastProperty {
-					wikiText += "," + val + "\n"
+					wikiText += sep + val + "\n"
 				} else {
 					wikiText += "|" + spacesToUnderscores(fact.Property) + "=" + val + "\n"
 				}
@@ -101,21 +184,31 @@ func (p *MWXMLCreator) Run() {
 			}
 
 			// Add categories as multi-valued call to the "categories" value of the template
-			wikiText += "|Categories="
+			wikiText += "|" + catParam + "="
 			for i, cat := range page.Categories {
 				if i == 0 {
 					wikiText += cat.Name
 				} else {
-					wikiText += "," + cat.Name
+					wikiText += sep + cat.Name
 				}
 			}
 
 			wikiText += "\n}}"
+
+			// Add inlined blank nodes after the template call
+			for _, subobject := range page.Subobjects {
+				wikiText += "\n" + str.TrimSuffix(subobject.asWikiString(p.conf.ValueEscaping), "\n")
+			}
 		} else {
 
 			// Add fact statements
 			for _, fact := range page.Facts {
-				wikiText += fact.asWikiFact()
+				wikiText += fact.asWikiFact(p.conf.ValueEscaping)
+			}
+
+			// Add inlined blank nodes
+			for _, subobject := range page.Subobjects {
+				wikiText += subobject.asWikiString(p.conf.ValueEscaping)
 			}
 
 			// Add category statements
@@ -125,49 +218,126 @@ func (p *MWXMLCreator) Run() {
 
 		}
 
-		xmlData := fmt.Sprintf(wikiXmlTpl, page.Title, pageTypeToMWNamespace[page.Type], time.Now().Format("2006-01-02T15:04:05Z"), wikiText)
+		xmlData := p.pageXML(page.Title, pageTypeToMWNamespace[page.Type], wikiText, "")
 
 		// Print out the generated XML one line at a time
 		if page.Type == URITypePredicate {
-			p.OutProperties <- xmlData
+			outProperties <- xmlData
+		} else if page.Type == URITypeClass {
+			outCategories <- xmlData
 		} else {
 			p.OutPages <- xmlData
 		}
 	}
-	p.OutPages <- "</mediawiki>\n"
-	p.OutProperties <- "</mediawiki>\n"
+	// Create redirect pages (in sorted order, so that the output is the same
+	// each time)
+	redirects := []string{}
+	for redirect := range redirectTargets {
+		if !pageTitles[redirect] {
+			redirects = append(redirects, redirect)
+		}
+	}
+	sort.Strings(redirects)
+	for _, redirect := range redirects {
+		if targets := redirectTargets[redirect]; len(targets) > 1 {
+			if p.conf.Redirects.DisambiguationPages && !str.HasPrefix(redirect, "Property:") {
+				p.OutPages <- p.pageXML(redirect, pageTypeToMWNamespace[URITypeUndefined], disambiguationText(redirect, targets), "")
+			}
+			continue
+		}
+		target := redirectTargets[redirect][0]
+		redirectText := "#REDIRECT [[" + target + "]]\n"
+		if str.HasPrefix(redirect, "Property:") {
+			outProperties <- p.pageXML(redirect, pageTypeToMWNamespace[URITypePredicate], redirectText, target)
+		} else {
+			p.OutPages <- p.pageXML(redirect, pageTypeToMWNamespace[URITypeUndefined], redirectText, target)
+		}
+	}
 
-	p.OutTemplates <- "<mediawiki>\n"
-	// Create template pages
-	for tplName, tplProperties := range tplPropertyIdx {
+	// Create template pages (in sorted order, so that the output is the same
+	// each time)
+	tplNames := []string{}
+	for tplName := range tplPropertyIdx {
+		tplNames = append(tplNames, tplName)
+	}
+	sort.Strings(tplNames)
+	for _, tplName := range tplNames {
 		tplText := `{|class="wikitable smwtable"
 !colspan="2"| ` + str.Replace(tplName, "Template:", "", -1) + `: {{PAGENAMEE}}
 `
-		for property := range tplProperties {
+		properties := []string{}
+		for property := range tplPropertyIdx[tplName] {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+		for _, property := range properties {
 			argName := spacesToUnderscores(property)
-			tplText += fmt.Sprintf("|-\n!%s\n|{{#arraymap:{{{%s|}}}|,|x|[[%s::x]]|,}}\n", property, argName, property)
+			tplText += fmt.Sprintf("|-\n!%s\n|{{#arraymap:{{{%s|}}}|%s|x|[[%s::x]]|,}}\n", property, argName, sep, property)
 		}
 		tplText += "|}\n\n"
 		// Add categories
-		tplText += "{{#arraymap:{{{Categories}}}|,|x|[[Category:x]]|}}\n"
+		tplText += fmt.Sprintf("{{#arraymap:{{{%s}}}|%s|x|[[Category:x]]|}}\n", catParam, sep)
 
-		xmlData := fmt.Sprintf(wikiXmlTpl, tplName, pageTypeToMWNamespace[URITypeTemplate], time.Now().Format("2006-01-02T15:04:05Z"), tplText)
-		p.OutTemplates <- xmlData
+		xmlData := p.pageXML(tplName, pageTypeToMWNamespace[URITypeTemplate], tplText, "")
+		outTemplates <- xmlData
+	}
+
+	for _, docOut := range docOuts {
+		docOut <- mwExportFooter
 	}
-	p.OutTemplates <- "</mediawiki>\n"
 }
 
-func spacesToUnderscores(inStr string) string {
-	return str.Replace(inStr, " ", "_", -1)
+// disambiguationText returns the wikitext of a disambiguation page for the
+// title shared by the pages targets, listing them in sorted order.
+func disambiguationText(title string, targets []string) string {
+	sorted := append([]string{}, targets...)
+	sort.Strings(sorted)
+	text := "'''" + title + "''' may refer to:\n"
+	for _, target := range sorted {
+		text += "* [[" + target + "]]\n"
+	}
+	return text
+}
+
+// categoryPageText returns the wikitext of a category page: the description
+// of the class, links to the template and form of the category, a listing of
+// the pages in the category (and its subcategories) with an #ask query, and
+// the facts, subobjects and parent categories of the class.
+func (p *MWXMLCreator) categoryPageText(page *WikiPage) string {
+	catName := str.TrimPrefix(page.Title, "Category:")
+	wikiText := ""
+
+	if description := str.TrimSpace(page.Description); description != "" {
+		description = escapeWikiValue(description, smwTypeText, p.conf.ValueEscaping, "")
+		// Characters starting lists, indented text or headings are only
+		// markup at the start of a line
+		if str.ContainsAny(description[:1], "*#:;=") {
+			description = characterReferences(description[:1]) + description[1:]
+		}
+		wikiText += description + "\n\n"
+	}
+
+	if p.UseTemplates {
+		wikiText += "* Template: [[Template:" + catName + "]]\n"
+	}
+	if p.conf.CategoryPages.FormNamespace != "" {
+		wikiText += "* Form: [[" + p.conf.CategoryPages.FormNamespace + ":" + catName + "]]\n"
+	}
+
+	wikiText += "\n== Members ==\n{{#ask: [[Category:" + catName + "]]\n|format=ul\n}}\n\n"
+
+	for _, fact := range page.Facts {
+		wikiText += fact.asWikiFact(p.conf.ValueEscaping)
+	}
+	for _, subobject := range page.Subobjects {
+		wikiText += subobject.asWikiString(p.conf.ValueEscaping)
+	}
+	for _, cat := range page.Categories {
+		wikiText += cat.asWikiString()
+	}
+	return wikiText
 }
 
-// TODO: Probably move out to separate component!
-func escapeWikiChars(inStr string) string {
-	outStr := str.Replace(inStr, "[", "(", -1)
-	outStr = str.Replace(outStr, "]", ")", -1)
-	outStr = str.Replace(outStr, "|", ",", -1)
-	outStr = str.Replace(outStr, "=", "-", -1)
-	outStr = str.Replace(outStr, "<", "&lt;", -1)
-	outStr = str.Replace(outStr, ">", "&gt;", -1)
-	return outStr
+func spacesToUnderscores(inStr string) string {
+	return str.Replace(inStr, " ", "_", -1)
 }
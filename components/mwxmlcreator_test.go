@@ -2,14 +2,18 @@ package components
 
 import (
 	"github.com/flowbase/flowbase"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // TestNewMWXMLCreator tests NewMWXMLCreator
 func TestNewMWXMLCreator(t *testing.T) {
 	flowbase.InitLogDebug()
 
-	mxc := NewMWXMLCreator(true)
+	mxc := NewMWXMLCreator(DefaultConfig())
 
 	if mxc.InWikiPage == nil {
 		t.Error("InWikiPage is not initialized")
@@ -20,6 +24,9 @@ func TestNewMWXMLCreator(t *testing.T) {
 	if mxc.OutProperties == nil {
 		t.Error("OutProperties is not initialized")
 	}
+	if mxc.OutCategories == nil {
+		t.Error("OutCategories is not initialized")
+	}
 	if mxc.OutPages == nil {
 		t.Error("OutPages is not initialized")
 	}
@@ -27,3 +34,333 @@ func TestNewMWXMLCreator(t *testing.T) {
 		t.Error("UseTemplates field is initialized wrongly")
 	}
 }
+
+// TestMWXMLCreatorInterleave tests that all pages go into one document on
+// OutPages when Interleave is set
+func TestMWXMLCreatorInterleave(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+
+	go func() {
+		defer close(mxc.InWikiPage)
+		mxc.InWikiPage <- NewWikiPage("Property:Has name", []*Fact{NewFact("Has type", "Text")}, []*Category{}, nil, URITypePredicate)
+		mxc.InWikiPage <- NewWikiPage("Alice", []*Fact{NewFact("Has name", "Alice")}, []*Category{NewCategory("Person")}, nil, URITypeUndefined)
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+	for range mxc.OutProperties {
+		t.Error("Got output on OutProperties in interleaved mode")
+	}
+	for range mxc.OutTemplates {
+		t.Error("Got output on OutTemplates in interleaved mode")
+	}
+
+	if strings.Count(output, "<mediawiki ") != 1 || strings.Count(output, "</mediawiki>") != 1 {
+		t.Error("Interleaved output is not exactly one mediawiki document:\n", output)
+	}
+	if !strings.HasSuffix(output, "</mediawiki>\n") {
+		t.Error("Interleaved output does not end with the closing mediawiki tag")
+	}
+	for _, title := range []string{"Property:Has name", "Alice", "Template:Person"} {
+		if !strings.Contains(output, "<title>"+title+"</title>") {
+			t.Errorf("Page %s missing from interleaved output", title)
+		}
+	}
+}
+
+// TestMWXMLCreatorTimestamp tests that a fixed Timestamp is used for all
+// revisions, and that template parameters are sorted
+func TestMWXMLCreatorTimestamp(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+	mxc.Timestamp = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	go func() {
+		defer close(mxc.InWikiPage)
+		mxc.InWikiPage <- NewWikiPage("Alice", []*Fact{NewFact("Has name", "Alice"), NewFact("Has age", "42")}, []*Category{NewCategory("Person")}, nil, URITypeUndefined)
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	if cnt := strings.Count(output, "<timestamp>2024-01-31T12:00:00Z</timestamp>"); cnt != 2 {
+		t.Errorf("Expected the fixed timestamp on 2 revisions, found it on %d:\n%s", cnt, output)
+	}
+	if strings.Index(output, "!Has age") > strings.Index(output, "!Has name") {
+		t.Error("Template parameters are not sorted:\n", output)
+	}
+}
+
+// TestMWXMLCreatorDomains tests that properties are added to the templates
+// of their domains, even when no page uses the template
+func TestMWXMLCreatorDomains(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+
+	go func() {
+		defer close(mxc.InWikiPage)
+		propPage := NewWikiPage("Property:Height", []*Fact{NewFact("Has type", "Number")}, []*Category{}, nil, URITypePredicate)
+		propPage.AddDomainUnique(NewCategory("Person"))
+		mxc.InWikiPage <- propPage
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	if !strings.Contains(output, "<title>Template:Person</title>") {
+		t.Fatal("Template for domain Person not created:\n", output)
+	}
+	if !strings.Contains(output, "[[Height::x]]") {
+		t.Error("Property Height missing from template for its domain:\n", output)
+	}
+}
+
+// TestMWXMLCreatorSubobjects tests that inlined blank nodes are written as
+// subobjects, with and without templates
+func TestMWXMLCreatorSubobjects(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	for _, useTemplates := range []bool{true, false} {
+		mxc := NewMWXMLCreator(DefaultConfig())
+		mxc.Interleave = true
+		mxc.UseTemplates = useTemplates
+
+		go func() {
+			defer close(mxc.InWikiPage)
+			page := NewWikiPage("Alice", []*Fact{NewFact("Address", "Alice#Address 1")}, []*Category{NewCategory("Person")}, nil, URITypeUndefined)
+			subobject := NewSubobject("Address 1")
+			subobject.AddFactUnique(NewFact("Street", "Main Street 1"))
+			subobject.AddFactUnique(NewFact("Note", "a|b"))
+			page.Subobjects = append(page.Subobjects, subobject)
+			mxc.InWikiPage <- page
+		}()
+		go mxc.Run()
+
+		output := ""
+		for s := range mxc.OutPages {
+			output += s
+		}
+
+		if !strings.Contains(output, "{{#subobject:Address 1\n|Street=Main Street 1\n|Note=a&amp;#124;b\n}}") {
+			t.Errorf("Subobject missing from output (templates: %v):\n%s", useTemplates, output)
+		}
+	}
+}
+
+// TestMWXMLCreatorRedirects tests that redirect pages are written for the
+// alternative titles of pages, except for titles of other pages, and that
+// titles shared by several pages get disambiguation pages
+func TestMWXMLCreatorRedirects(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+
+	go func() {
+		defer close(mxc.InWikiPage)
+		mercury := NewWikiPage("Mercury", []*Fact{}, []*Category{}, nil, URITypeUndefined)
+		mercury.Redirects = []string{"Hermes", "Quicksilver", "Venus"}
+		venus := NewWikiPage("Venus", []*Fact{}, []*Category{}, nil, URITypeUndefined)
+		venus.Redirects = []string{"Quicksilver"}
+		name := NewWikiPage("Property:Name", []*Fact{}, []*Category{}, nil, URITypePredicate)
+		name.Redirects = []string{"Property:Label"}
+		mxc.InWikiPage <- mercury
+		mxc.InWikiPage <- venus
+		mxc.InWikiPage <- name
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	if !strings.Contains(output, "<title>Hermes</title>\n    <ns>0</ns>\n    <id>4</id>\n    <redirect title=\"Mercury\">") || !strings.Contains(output, "#REDIRECT [[Mercury]]") {
+		t.Error("Redirect from Hermes to Mercury missing:\n", output)
+	}
+	if !strings.Contains(output, "<title>Property:Label</title>\n    <ns>102</ns>") || !strings.Contains(output, "#REDIRECT [[Property:Name]]") {
+		t.Error("Redirect from Property:Label to Property:Name missing:\n", output)
+	}
+	if strings.Contains(output, "<redirect title=\"Venus\">\n") || !strings.Contains(output, "<title>Quicksilver</title>") ||
+		!strings.Contains(output, "&#39;&#39;&#39;Quicksilver&#39;&#39;&#39; may refer to:\n* [[Mercury]]\n* [[Venus]]\n") {
+		t.Error("Disambiguation page for title shared by several pages missing:\n", output)
+	}
+	if strings.Count(output, "<title>Venus</title>") != 1 {
+		t.Error("Redirect for title of existing page should be skipped:\n", output)
+	}
+}
+
+// TestMWXMLCreatorSchema tests that each of the documents written validates
+// against the MediaWiki export-0.11 schema (using xmllint, if installed)
+func TestMWXMLCreatorSchema(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not installed")
+	}
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	go func() {
+		defer close(mxc.InWikiPage)
+		propPage := NewWikiPage("Property:R&D <budget>", []*Fact{NewFact("Has type", "Number")}, []*Category{}, nil, URITypePredicate)
+		propPage.AddDomainUnique(NewCategory("Department"))
+		propPage.Redirects = []string{"Property:Research & development"}
+		mxc.InWikiPage <- propPage
+		page := NewWikiPage("Sales & \"Marketing\"", []*Fact{NewFact("R&D <budget>", "1000")}, []*Category{NewCategory("Department")}, nil, URITypeUndefined)
+		page.Redirects = []string{"S&M"}
+		mxc.InWikiPage <- page
+	}()
+	go mxc.Run()
+
+	outputs := make([]string, 3)
+	wg := &sync.WaitGroup{}
+	for i, out := range []chan string{mxc.OutPages, mxc.OutProperties, mxc.OutTemplates} {
+		wg.Add(1)
+		go func(i int, out chan string) {
+			defer wg.Done()
+			for s := range out {
+				outputs[i] += s
+			}
+		}(i, out)
+	}
+	wg.Wait()
+
+	for _, output := range outputs {
+		cmd := exec.Command(xmllint, "--noout", "--schema", "testdata/export-0.11.xsd", "-")
+		cmd.Stdin = strings.NewReader(output)
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Output does not validate against the schema: %s\n%s", msg, output)
+		}
+	}
+	if !strings.Contains(outputs[0], `<redirect title="Sales &amp; &#34;Marketing&#34;">`) {
+		t.Error("Redirect element missing:\n", outputs[0])
+	}
+}
+
+// TestMWXMLCreatorSiteInfo tests that the siteinfo element describes the
+// wiki as configured
+func TestMWXMLCreatorSiteInfo(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	conf := DefaultConfig()
+	conf.CapitalLinks = false
+	conf.SiteInfo.Sitename = "My Wiki"
+	conf.SiteInfo.Namespaces = append(conf.SiteInfo.Namespaces, NamespaceConfig{Key: 108, Name: "Concept"})
+	mxc := NewMWXMLCreator(conf)
+	mxc.Interleave = true
+	close(mxc.InWikiPage)
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	for _, expected := range []string{
+		"<sitename>My Wiki</sitename>",
+		"<case>case-sensitive</case>",
+		`<namespace key="0" case="case-sensitive"></namespace>`,
+		`<namespace key="108" case="case-sensitive">Concept</namespace>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("%s missing from siteinfo:\n%s", expected, output)
+		}
+	}
+}
+
+// TestMWSHA1 tests that hashes are written in base 36, as by MediaWiki
+func TestMWSHA1(t *testing.T) {
+	if hash := mwSHA1(""); hash != "phoiac9h4m842xq45sp7s6u21eteeq1" {
+		t.Errorf("Wrong hash of empty text: %s", hash)
+	}
+}
+
+// TestMWXMLCreatorSeparateCategories tests that category pages are written
+// to OutCategories, as a document of their own, when SeparateCategories is
+// set
+func TestMWXMLCreatorSeparateCategories(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.SeparateCategories = true
+	go func() {
+		defer close(mxc.InWikiPage)
+		mxc.InWikiPage <- NewWikiPage("Category:Person", []*Fact{}, []*Category{}, nil, URITypeClass)
+		mxc.InWikiPage <- NewWikiPage("Alice", []*Fact{}, []*Category{NewCategory("Person")}, nil, URITypeUndefined)
+	}()
+	go mxc.Run()
+
+	outputs := make([]string, 4)
+	wg := &sync.WaitGroup{}
+	for i, out := range []chan string{mxc.OutPages, mxc.OutCategories, mxc.OutProperties, mxc.OutTemplates} {
+		wg.Add(1)
+		go func(i int, out chan string) {
+			defer wg.Done()
+			for s := range out {
+				outputs[i] += s
+			}
+		}(i, out)
+	}
+	wg.Wait()
+
+	if strings.Contains(outputs[0], "<title>Category:Person</title>") || !strings.Contains(outputs[0], "<title>Alice</title>") {
+		t.Error("Wrong pages on OutPages:\n", outputs[0])
+	}
+	if !strings.Contains(outputs[1], "<title>Category:Person</title>") || !strings.HasSuffix(outputs[1], "</mediawiki>\n") {
+		t.Error("Category page missing from OutCategories:\n", outputs[1])
+	}
+}
+
+// TestMWXMLCreatorCategoryPages tests that category pages get their
+// description, links to their template and form, a listing of members and
+// their parent categories, and that their templates are created
+func TestMWXMLCreatorCategoryPages(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	mxc := NewMWXMLCreator(DefaultConfig())
+	mxc.Interleave = true
+	go func() {
+		defer close(mxc.InWikiPage)
+		page := NewWikiPage("Category:Person", []*Fact{NewFact("Label", "Person")}, []*Category{NewCategory("Agent")}, nil, URITypeClass)
+		page.Description = "# A human | being"
+		mxc.InWikiPage <- page
+	}()
+	go mxc.Run()
+
+	output := ""
+	for s := range mxc.OutPages {
+		output += s
+	}
+
+	for _, expected := range []string{
+		"&amp;#35; A human &amp;#124; being\n\n",
+		"* Template: [[Template:Person]]\n* Form: [[Form:Person]]\n",
+		"{{#ask: [[Category:Person]]\n|format=ul\n}}",
+		"[[Label::Person]]\n[[Category:Agent]]\n",
+		"<title>Template:Person</title>",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("%q missing from output:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "{{Agent") {
+		t.Error("Category page should not call a template:\n", output)
+	}
+}
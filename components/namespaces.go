@@ -0,0 +1,241 @@
+package components
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	str "strings"
+	"sync"
+)
+
+// Policies for when titles are made from an abbreviated namespace and the
+// local name of a URI (such as "dc:title"), rather than the local name only
+const (
+	// AbbreviateNone never abbreviates namespaces
+	AbbreviateNone = "none"
+	// AbbreviateProperties abbreviates the namespaces of properties only
+	AbbreviateProperties = "properties"
+	// AbbreviateAll abbreviates the namespaces of all URIs
+	AbbreviateAll = "all"
+	// AbbreviateOnCollision abbreviates the namespaces of URIs whose local
+	// names are shared by other URIs in the input
+	AbbreviateOnCollision = "collision"
+)
+
+var abbreviationPolicies = []string{AbbreviateNone, AbbreviateProperties, AbbreviateAll, AbbreviateOnCollision}
+
+// commonPrefixes holds the prefixes of widely used vocabularies, as listed by
+// prefix.cc, used when neither the configuration nor the input declares a
+// prefix for a namespace.
+var commonPrefixes = map[string]string{
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#":      "rdf",
+	"http://www.w3.org/2000/01/rdf-schema#":            "rdfs",
+	"http://www.w3.org/2002/07/owl#":                   "owl",
+	"http://www.w3.org/2001/XMLSchema#":                "xsd",
+	"http://purl.org/dc/elements/1.1/":                 "dc",
+	"http://purl.org/dc/terms/":                        "dcterms",
+	"http://xmlns.com/foaf/0.1/":                       "foaf",
+	"http://www.w3.org/2004/02/skos/core#":             "skos",
+	"http://schema.org/":                               "schema",
+	"https://schema.org/":                              "schema",
+	"http://www.w3.org/2003/01/geo/wgs84_pos#":         "geo",
+	"http://www.opengis.net/ont/geosparql#":            "geosparql",
+	"http://www.w3.org/ns/prov#":                       "prov",
+	"http://rdfs.org/ns/void#":                         "void",
+	"http://www.w3.org/ns/dcat#":                       "dcat",
+	"http://www.w3.org/2006/vcard/ns#":                 "vcard",
+	"http://rdfs.org/sioc/ns#":                         "sioc",
+	"http://usefulinc.com/ns/doap#":                    "doap",
+	"http://purl.org/ontology/bibo/":                   "bibo",
+	"http://www.w3.org/ns/org#":                        "org",
+	"http://purl.org/goodrelations/v1#":                "gr",
+	"http://dbpedia.org/ontology/":                     "dbo",
+	"http://dbpedia.org/property/":                     "dbp",
+	"http://dbpedia.org/resource/":                     "dbr",
+	"http://www.wikidata.org/entity/":                  "wd",
+	"http://www.wikidata.org/prop/direct/":             "wdt",
+	"http://purl.obolibrary.org/obo/":                  "obo",
+	"http://semantic-mediawiki.org/swivt/1.0#":         "swivt",
+	"http://www.w3.org/ns/shacl#":                      "sh",
+	"http://purl.org/vocab/vann/":                      "vann",
+	"http://creativecommons.org/ns#":                   "cc",
+	"http://www.w3.org/2000/10/swap/pim/contact#":      "contact",
+	"http://purl.org/linked-data/cube#":                "qb",
+	"http://www.w3.org/2006/time#":                     "time",
+	"http://www.w3.org/ns/locn#":                       "locn",
+	"http://www.w3.org/2011/http#":                     "http",
+	"http://www.w3.org/ns/adms#":                       "adms",
+	"http://purl.org/NET/c4dm/event.owl#":              "event",
+	"http://www.geonames.org/ontology#":                "gn",
+	"http://www.w3.org/1999/xhtml/vocab#":              "xhv",
+	"http://www.w3.org/ns/sosa/":                       "sosa",
+	"http://www.w3.org/ns/ssn/":                        "ssn",
+	"http://qudt.org/schema/qudt/":                     "qudt",
+	"http://purl.org/ontology/mo/":                     "mo",
+	"http://www.w3.org/ns/odrl/2/":                     "odrl",
+	"http://www.w3.org/ns/activitystreams#":            "as",
+	"http://www.w3.org/2002/12/cal/ical#":              "ical",
+	"http://purl.org/spar/fabio/":                      "fabio",
+	"http://purl.org/spar/cito/":                       "cito",
+	"http://www.w3.org/2007/05/powder-s#":              "wdrs",
+	"http://www.w3.org/ns/earl#":                       "earl",
+	"http://www.w3.org/ns/ldp#":                        "ldp",
+	"http://www.w3.org/ns/csvw#":                       "csvw",
+	"http://www.opengis.net/ont/sf#":                   "sf",
+	"http://www.w3.org/2008/05/skos-xl#":               "skosxl",
+	"http://purl.org/vocab/relationship/":              "rel",
+	"http://purl.org/vocab/bio/0.1/":                   "bio",
+	"http://www.w3.org/2001/vcard-rdf/3.0#":            "vcard3",
+	"http://www.w3.org/ns/dqv#":                        "dqv",
+	"http://www.w3.org/ns/oa#":                         "oa",
+	"http://www.w3.org/ns/r2rml#":                      "rr",
+	"http://www.w3.org/ns/solid/terms#":                "solid",
+	"http://www.w3.org/ns/auth/acl#":                   "acl",
+	"http://www.w3.org/ns/auth/cert#":                  "cert",
+	"http://www.w3.org/ns/pim/space#":                  "space",
+	"http://www.w3.org/ns/ma-ont#":                     "ma",
+	"http://www.w3.org/ns/regorg#":                     "rov",
+	"http://www.w3.org/ns/person#":                     "person",
+	"http://www.w3.org/ns/formats/":                    "formats",
+	"http://www.w3.org/ns/rdfa#":                       "rdfa",
+	"http://www.w3.org/ns/hydra/core#":                 "hydra",
+	"http://www.w3.org/2003/06/sw-vocab-status/ns#":    "vs",
+	"http://www.w3.org/2003/11/swrl#":                  "swrl",
+	"http://www.w3.org/ns/sparql-service-description#": "sd",
+}
+
+// NamespacePrefixes maps namespace URIs to prefixes, used to abbreviate URIs
+// into titles such as "dc:title". Prefixes come from three sources, in order
+// of priority: the configuration, prefix declarations in the input (added
+// with Add as the input is read), and commonPrefixes.
+//
+// NamespacePrefixes is safe for concurrent use.
+type NamespacePrefixes struct {
+	configured map[string]string
+	input      map[string]string
+	mu         sync.RWMutex
+}
+
+// NewNamespacePrefixes returns a NamespacePrefixes with the configured
+// prefixes, mapped from namespace URIs as in Config.NamespaceAbbreviations.
+func NewNamespacePrefixes(configured map[string]string) *NamespacePrefixes {
+	return &NamespacePrefixes{
+		configured: configured,
+		input:      make(map[string]string),
+	}
+}
+
+// Add adds a prefix declared in the input for the namespace ns. Namespaces
+// already declared keep their first prefix.
+func (np *NamespacePrefixes) Add(ns string, prefix string) {
+	if !isAbsoluteURI(ns) || prefix == "" {
+		return
+	}
+	np.mu.Lock()
+	defer np.mu.Unlock()
+	if _, ok := np.input[ns]; !ok {
+		np.input[ns] = prefix
+	}
+}
+
+// Abbreviate splits uri into the prefix of the longest known namespace it
+// starts with, and the rest of the URI. The last return value is false if no
+// namespace matches, or nothing is left after the namespace.
+func (np *NamespacePrefixes) Abbreviate(uri string) (prefix string, localName string, ok bool) {
+	np.mu.RLock()
+	defer np.mu.RUnlock()
+	bestNS := ""
+	for _, prefixes := range []map[string]string{np.configured, np.input, commonPrefixes} {
+		for ns, nsPrefix := range prefixes {
+			if len(ns) > len(bestNS) && len(uri) > len(ns) && str.HasPrefix(uri, ns) {
+				bestNS, prefix = ns, nsPrefix
+			}
+		}
+	}
+	if bestNS == "" {
+		return "", "", false
+	}
+	// Take the prefix from the source with the highest priority
+	for _, prefixes := range []map[string]string{np.configured, np.input} {
+		if nsPrefix, ok := prefixes[bestNS]; ok {
+			prefix = nsPrefix
+			break
+		}
+	}
+	return prefix, uri[len(bestNS):], true
+}
+
+var (
+	turtlePrefixRegex = regexp.MustCompile(`^\s*(?:@prefix|(?i:prefix))\s+([A-Za-z][\w.-]*)?:\s*<([^>]*)>`)
+	xmlnsPrefixRegex  = regexp.MustCompile(`xmlns:([A-Za-z_][\w.-]*)\s*=\s*["']([^"']*)["']`)
+)
+
+// maxPrefixScanBuffer is the number of bytes the prefixScanningReader keeps
+// at most of an unfinished line (or XML tag). Longer ones, such as long
+// literals, can not be prefix declarations, and are not scanned.
+const maxPrefixScanBuffer = 64 * 1024
+
+// prefixScanningReader passes on what it reads from r, while adding the
+// namespace prefixes declared in it (Turtle @prefix / PREFIX lines, or XML
+// xmlns attributes) to prefixes. Turtle is scanned line by line, and XML tag
+// by tag, as it may have no line breaks at all.
+type prefixScanningReader struct {
+	r        io.Reader
+	prefixes *NamespacePrefixes
+	xml      bool
+	partial  []byte
+	skipping bool
+}
+
+func newPrefixScanningReader(r io.Reader, prefixes *NamespacePrefixes, xml bool) *prefixScanningReader {
+	return &prefixScanningReader{r: r, prefixes: prefixes, xml: xml}
+}
+
+func (s *prefixScanningReader) Read(buf []byte) (int, error) {
+	n, err := s.r.Read(buf)
+	s.partial = append(s.partial, buf[:n]...)
+	end := byte('\n')
+	if s.xml {
+		end = '>'
+	}
+	if i := bytes.LastIndexByte(s.partial, end); i >= 0 {
+		start := 0
+		if s.skipping {
+			// Skip the rest of the line (or tag) that was too long
+			start = bytes.IndexByte(s.partial, end) + 1
+			s.skipping = false
+		}
+		s.scanLines(s.partial[start : i+1])
+		s.partial = append(s.partial[:0], s.partial[i+1:]...)
+	}
+	if len(s.partial) > maxPrefixScanBuffer {
+		s.partial = s.partial[:0]
+		s.skipping = true
+	}
+	if err != nil {
+		if !s.skipping {
+			s.scanLines(s.partial)
+		}
+		s.partial = nil
+	}
+	return n, err
+}
+
+func (s *prefixScanningReader) scanLines(data []byte) {
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for lines.Scan() {
+		line := lines.Text()
+		if s.xml {
+			if !str.Contains(line, "xmlns:") {
+				continue
+			}
+			for _, m := range xmlnsPrefixRegex.FindAllStringSubmatch(line, -1) {
+				s.prefixes.Add(m[2], m[1])
+			}
+		} else if m := turtlePrefixRegex.FindStringSubmatch(line); m != nil {
+			s.prefixes.Add(m[2], m[1])
+		}
+	}
+}
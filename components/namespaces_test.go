@@ -0,0 +1,112 @@
+package components
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNamespacePrefixesAbbreviate(t *testing.T) {
+	np := NewNamespacePrefixes(map[string]string{"http://example.org/": "ex"})
+	np.Add("http://example.org/vocab#", "voc")
+	np.Add("http://example.org/vocab#", "other")
+	np.Add("http://xmlns.com/foaf/0.1/", "f")
+
+	tests := map[string]string{
+		"http://example.org/Alice":        "ex Alice",
+		"http://example.org/vocab#name":   "voc name",
+		"http://xmlns.com/foaf/0.1/knows": "f knows",
+		"http://purl.org/dc/terms/title":  "dcterms title",
+	}
+	for uri, expected := range tests {
+		prefix, name, ok := np.Abbreviate(uri)
+		if !ok || prefix+" "+name != expected {
+			t.Errorf("Wrong abbreviation of %s (Expected %s, got %s %s, %v)", uri, expected, prefix, name, ok)
+		}
+	}
+	for _, uri := range []string{"http://example.org/", "http://unknown.org/thing"} {
+		if _, _, ok := np.Abbreviate(uri); ok {
+			t.Errorf("Got abbreviation for %s", uri)
+		}
+	}
+}
+
+func TestPrefixScanningReader(t *testing.T) {
+	turtle := "@prefix ex: <http://example.org/> .\n" +
+		"PREFIX voc: <http://example.org/vocab#>\n" +
+		"@prefix : <http://example.org/default#> .\n" +
+		"ex:Alice voc:name \"@prefix no: <http://example.org/no#> .\" .\n" +
+		"@prefix last: <http://example.org/last#> ."
+	np := NewNamespacePrefixes(nil)
+	// Read one byte at a time, to test declarations split over reads
+	data, err := io.ReadAll(newPrefixScanningReader(iotest.OneByteReader(strings.NewReader(turtle)), np, false))
+	if err != nil || string(data) != turtle {
+		t.Fatal("Data not passed on unchanged")
+	}
+	for ns, prefix := range map[string]string{
+		"http://example.org/":         "ex",
+		"http://example.org/vocab#":   "voc",
+		"http://example.org/last#":    "last",
+		"http://example.org/default#": "",
+		"http://example.org/no#":      "",
+	} {
+		if np.input[ns] != prefix {
+			t.Errorf("Wrong prefix for %s (Expected %q, got %q)", ns, prefix, np.input[ns])
+		}
+	}
+
+	rdfXML := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex='http://example.org/'>`
+	np = NewNamespacePrefixes(nil)
+	io.ReadAll(newPrefixScanningReader(strings.NewReader(rdfXML), np, true))
+	if np.input["http://example.org/"] != "ex" || np.input["http://www.w3.org/1999/02/22-rdf-syntax-ns#"] != "rdf" {
+		t.Errorf("XML namespace declarations not found: %v", np.input)
+	}
+}
+
+// TestPrefixScanningReaderBuffer tests that the reader does not buffer
+// everything up to a line break, which one-line RDF/XML may not have
+func TestPrefixScanningReaderBuffer(t *testing.T) {
+	rdfXML := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="http://example.org/s"><ex:p xmlns:ex="http://example.org/">` +
+		strings.Repeat("long literal ", 20000) +
+		`</ex:p></rdf:Description><rdf:Description xmlns:voc="http://example.org/vocab#"/></rdf:RDF>`
+	np := NewNamespacePrefixes(nil)
+	r := newPrefixScanningReader(strings.NewReader(rdfXML), np, true)
+	buf := make([]byte, 1024)
+	for _, err := r.Read(buf); err == nil; _, err = r.Read(buf) {
+		if len(r.partial) > maxPrefixScanBuffer+len(buf) {
+			t.Fatalf("Buffered %d bytes of one-line RDF/XML", len(r.partial))
+		}
+	}
+	for ns, prefix := range map[string]string{
+		"http://www.w3.org/1999/02/22-rdf-syntax-ns#": "rdf",
+		"http://example.org/":                         "ex",
+		"http://example.org/vocab#":                   "voc",
+	} {
+		if np.input[ns] != prefix {
+			t.Errorf("Wrong prefix for %s (Expected %q, got %q)", ns, prefix, np.input[ns])
+		}
+	}
+
+	// A Turtle line longer than the buffer is skipped, also the part of it
+	// read after the buffer was dropped, which here starts right at the
+	// next read
+	start := "ex:s ex:p \""
+	dropAt := (maxPrefixScanBuffer/len(buf) + 1) * len(buf)
+	turtle := start + strings.Repeat("x", dropAt-len(start)) + "@prefix no: <http://example.org/no#> .\" .\n" +
+		"@prefix ex: <http://example.org/> .\n"
+	np = NewNamespacePrefixes(nil)
+	r = newPrefixScanningReader(strings.NewReader(turtle), np, false)
+	for _, err := r.Read(buf); err == nil; _, err = r.Read(buf) {
+		if len(r.partial) > maxPrefixScanBuffer+len(buf) {
+			t.Fatalf("Buffered %d bytes of a long Turtle line", len(r.partial))
+		}
+	}
+	if np.input["http://example.org/"] != "ex" {
+		t.Error("Prefix after a long line not found")
+	}
+	if _, ok := np.input["http://example.org/no#"]; ok {
+		t.Error("Prefix found in the rest of a long line")
+	}
+}
@@ -0,0 +1,292 @@
+package components
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	str "strings"
+
+	"github.com/flowbase/flowbase"
+	"github.com/knakk/rdf"
+	"github.com/spf13/afero"
+)
+
+// RDFFileReader is a process that reads RDF files, based on file names it
+// receives on the RDFFileReader.InFileName port / channel, and writes out the
+// parsed triples on the RDFFileReader.OutTriple port / channel.
+//
+// The serialization format of each file is taken from the Format field if
+// set, or otherwise detected from the file extension (see DetectRDFFormat).
+// TriG files are read as Turtle, with their graph names dropped (see
+// trigReader), and JSON-LD files as N-Quads, once converted in full (see
+// jsonLDToNQuads). Files compressed with gzip, bzip2 or xz are decompressed
+// on the fly. The file name StdioFileName ("-") reads from standard input, in
+// which case the format defaults to Turtle.
+//
+// Since blank node labels are only unique within a file, blank nodes in the
+// second file onwards get their labels prefixed with the file number, so that
+// they are not merged with blank nodes from other files.
+//
+// If Prefixes is set, the namespace prefixes declared in Turtle and RDF/XML
+// files are added to it as the files are read.
+//
+// Files or triples that can not be read are skipped, and reported as
+// *ConversionError's on the OutError port.
+//
+// If Stop is set and gets closed (as by ErrorCollector, on failure), reading
+// stops, and the remaining file names are received but not read.
+type RDFFileReader struct {
+	InFileName chan string
+	OutTriple  chan rdf.Triple
+	OutError   chan *ConversionError
+	Stop       <-chan struct{}
+	Format     string
+	Prefixes   *NamespacePrefixes
+	fs         afero.Fs
+	stdin      io.Reader
+}
+
+// NewOsRDFFileReader returns an initialized RDFFileReader, with an OS
+// (normal) file system
+func NewOsRDFFileReader() *RDFFileReader {
+	return NewRDFFileReader(afero.NewOsFs())
+}
+
+// NewRDFFileReader returns an initialized RDFFileReader, initialized with the
+// afero file system provided as an argument
+func NewRDFFileReader(fileSystem afero.Fs) *RDFFileReader {
+	return &RDFFileReader{
+		InFileName: make(chan string, BUFSIZE),
+		OutTriple:  make(chan rdf.Triple, BUFSIZE),
+		OutError:   make(chan *ConversionError, BUFSIZE),
+		fs:         fileSystem,
+		stdin:      os.Stdin,
+	}
+}
+
+// Run runs the RDFFileReader process. It does not spawn a separate
+// go-routine, so you have to prepend the go keyword when calling it, in order
+// to have it run in a separate go-routine.
+func (p *RDFFileReader) Run() {
+	defer close(p.OutTriple)
+	defer close(p.OutError)
+
+	fileNo := 0
+	flowbase.Debug.Println("Starting loop")
+	for fileName := range p.InFileName {
+		if p.stopped() {
+			continue
+		}
+		flowbase.Debug.Printf("Starting processing file %s\n", fileName)
+		fileNo++
+		p.readFile(fileName, fileNo)
+	}
+}
+
+// maxConsecutiveErrors is the number of decoding errors in a row after which
+// the rest of a file is skipped, as the decoder is then unlikely to recover.
+const maxConsecutiveErrors = 100
+
+func (p *RDFFileReader) readFile(fileName string, fileNo int) {
+	formatName := p.Format
+	if formatName == "" {
+		formatName = DetectRDFFormat(fileName)
+	}
+	format, err := ParseRDFFormat(formatName)
+	if err != nil {
+		p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("can not read file: %s", err.Error()))
+		return
+	}
+
+	fh, err := p.open(fileName)
+	if err != nil {
+		p.OutError <- NewConversionError(fileName, 0, "", err)
+		return
+	}
+	defer fh.Close()
+
+	r, err := newDecompressingReader(fh)
+	if err != nil {
+		p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("could not decompress file: %s", err.Error()))
+		return
+	}
+
+	switch rdfFormatNames[str.ToLower(formatName)] {
+	case RDFFormatTriG:
+		r = newTriGReader(r)
+	case RDFFormatJSONLD:
+		r, err = jsonLDToNQuads(r)
+		if err != nil {
+			p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("could not convert JSON-LD: %s", err.Error()))
+			return
+		}
+	}
+
+	if p.Prefixes != nil && (format == rdf.Turtle || format == rdf.RDFXML) {
+		r = newPrefixScanningReader(r, p.Prefixes, format == rdf.RDFXML)
+	}
+
+	errCnt := 0
+	dec := newTripleDecoder(r, format)
+	for triple, err := dec.Decode(); err != io.EOF; triple, err = dec.Decode() {
+		if p.stopped() {
+			return
+		}
+		if err != nil {
+			p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("could not decode triple: %s", err.Error()))
+			errCnt++
+			if errCnt >= maxConsecutiveErrors {
+				p.OutError <- NewConversionError(fileName, 0, "", fmt.Errorf("skipping rest of file after %d errors in a row", errCnt))
+				return
+			}
+		} else if triple.Subj != nil && triple.Pred != nil && triple.Obj != nil {
+			errCnt = 0
+			if fileNo > 1 {
+				triple = scopeBlankNodes(triple, fileNo)
+			}
+			p.OutTriple <- triple
+		} else {
+			p.OutError <- NewConversionError(fileName, 0, fmt.Sprint(triple), fmt.Errorf("triple with missing subject, predicate or object"))
+		}
+	}
+}
+
+// stopped tells whether the Stop channel is closed.
+func (p *RDFFileReader) stopped() bool {
+	select {
+	case <-p.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// open opens the file fileName, or standard input for StdioFileName.
+func (p *RDFFileReader) open(fileName string) (io.ReadCloser, error) {
+	if fileName == StdioFileName {
+		return io.NopCloser(p.stdin), nil
+	}
+	return p.fs.Open(fileName)
+}
+
+// scopeBlankNodes prefixes the labels of blank nodes in triple with the file
+// number fileNo.
+func scopeBlankNodes(triple rdf.Triple, fileNo int) rdf.Triple {
+	if subj, ok := triple.Subj.(rdf.Blank); ok {
+		triple.Subj = scopedBlank(subj, fileNo)
+	}
+	if obj, ok := triple.Obj.(rdf.Blank); ok {
+		triple.Obj = scopedBlank(obj, fileNo)
+	}
+	return triple
+}
+
+func scopedBlank(b rdf.Blank, fileNo int) rdf.Blank {
+	scoped, err := rdf.NewBlank(fmt.Sprintf("f%d_%s", fileNo, b.String()))
+	if err != nil {
+		return b
+	}
+	return scoped
+}
+
+// --------------------------------------------------------------------------------
+// RDF formats
+// --------------------------------------------------------------------------------
+
+// Names of the RDF serialization formats understood by ParseRDFFormat.
+const (
+	RDFFormatTurtle   = "turtle"
+	RDFFormatNTriples = "ntriples"
+	RDFFormatRDFXML   = "rdfxml"
+	RDFFormatNQuads   = "nquads"
+	RDFFormatTriG     = "trig"
+	RDFFormatJSONLD   = "jsonld"
+)
+
+var rdfFormatNames = map[string]string{
+	"turtle":    RDFFormatTurtle,
+	"ttl":       RDFFormatTurtle,
+	"ntriples":  RDFFormatNTriples,
+	"n-triples": RDFFormatNTriples,
+	"nt":        RDFFormatNTriples,
+	"rdfxml":    RDFFormatRDFXML,
+	"rdf/xml":   RDFFormatRDFXML,
+	"xml":       RDFFormatRDFXML,
+	"nquads":    RDFFormatNQuads,
+	"n-quads":   RDFFormatNQuads,
+	"nq":        RDFFormatNQuads,
+	"trig":      RDFFormatTriG,
+	"jsonld":    RDFFormatJSONLD,
+	"json-ld":   RDFFormatJSONLD,
+}
+
+var rdfFormatExtensions = map[string]string{
+	".ttl":    RDFFormatTurtle,
+	".n3":     RDFFormatTurtle,
+	".nt":     RDFFormatNTriples,
+	".rdf":    RDFFormatRDFXML,
+	".owl":    RDFFormatRDFXML,
+	".xml":    RDFFormatRDFXML,
+	".nq":     RDFFormatNQuads,
+	".trig":   RDFFormatTriG,
+	".jsonld": RDFFormatJSONLD,
+}
+
+// DetectRDFFormat returns the name of the RDF format of a file, based on its
+// file extension, ignoring any compression extension (such as in
+// "data.nt.gz"). Files with unknown extensions are assumed to be in Turtle,
+// which is a superset of N-Triples.
+func DetectRDFFormat(fileName string) string {
+	if format, ok := rdfFormatExtensions[str.ToLower(filepath.Ext(TrimCompressionExt(fileName)))]; ok {
+		return format
+	}
+	return RDFFormatTurtle
+}
+
+// ParseRDFFormat returns the rdf.Format for a format name, such as "turtle",
+// "ntriples", "rdfxml", "nquads", "trig" or "jsonld" (or common abbreviations
+// of them), or an error if the format is unknown. TriG is decoded as Turtle,
+// once converted by trigReader, and JSON-LD as N-Quads, once converted by
+// jsonLDToNQuads.
+func ParseRDFFormat(name string) (rdf.Format, error) {
+	formatName, ok := rdfFormatNames[str.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown RDF format: %s", name)
+	}
+	switch formatName {
+	case RDFFormatTurtle, RDFFormatTriG:
+		return rdf.Turtle, nil
+	case RDFFormatNTriples:
+		return rdf.NTriples, nil
+	case RDFFormatRDFXML:
+		return rdf.RDFXML, nil
+	case RDFFormatNQuads, RDFFormatJSONLD:
+		return rdf.NQuads, nil
+	}
+	return 0, fmt.Errorf("unknown RDF format: %s", name)
+}
+
+// tripleDecoder is the part of rdf.TripleDecoder used by the readers, which
+// lets quad formats be read as triples as well.
+type tripleDecoder interface {
+	Decode() (rdf.Triple, error)
+}
+
+func newTripleDecoder(r io.Reader, format rdf.Format) tripleDecoder {
+	if format == rdf.NQuads {
+		return &quadTripleDecoder{dec: rdf.NewQuadDecoder(r, format)}
+	}
+	return rdf.NewTripleDecoder(r, format)
+}
+
+// quadTripleDecoder decodes quads, returning only their triple part, since
+// the graph name has no correspondence in the wiki.
+type quadTripleDecoder struct {
+	dec *rdf.QuadDecoder
+}
+
+func (d *quadTripleDecoder) Decode() (rdf.Triple, error) {
+	quad, err := d.dec.Decode()
+	return quad.Triple, err
+}
@@ -0,0 +1,252 @@
+package components
+
+import (
+	"github.com/flowbase/flowbase"
+	"github.com/spf13/afero"
+	"strings"
+	"testing"
+)
+
+// TestNewRDFFileReader tests NewOsRDFFileReader
+func TestNewRDFFileReader(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fr := NewOsRDFFileReader()
+	if fr.InFileName == nil {
+		t.Error("In-port InFileName not initialized in New FileReader")
+	}
+	if fr.OutTriple == nil {
+		t.Error("In-port InFileName not initialized in New FileReader")
+	}
+
+	go func() {
+		fr.InFileName <- "teststring"
+	}()
+	teststr1 := <-fr.InFileName
+	if teststr1 != "teststring" {
+		t.Error("In-port InFileName is not a string channel")
+		fr.InFileName <- "teststring"
+	}
+}
+
+// Tests the main behavior of the RDFFileReader process
+func TestRDFFileReader(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	s1 := "http://example.org/s1"
+	p1 := "http://example.org/p1"
+	o1 := "string1"
+	s2 := "http://example.org/p2"
+	p2 := "http://example.org/p2"
+	o2 := "string2"
+	triple1 := "<" + s1 + "> <" + p1 + "> \"" + o1 + "\" ."
+	triple2 := "<" + s2 + "> <" + p2 + "> \"" + o2 + "\" ."
+	testContent := triple1 + "\n" + triple2
+
+	fs := afero.NewMemMapFs()
+
+	testFileName := "testfile.ttl"
+	f, err := fs.Create(testFileName)
+	if err != nil {
+		t.Errorf("Could not create file %s in memory file system", testFileName)
+	}
+	f.WriteString(testContent)
+	f.Close()
+
+	fr := NewRDFFileReader(fs)
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- testFileName
+	}()
+
+	go fr.Run()
+
+	outTriple1 := <-fr.OutTriple
+	outTriple2 := <-fr.OutTriple
+
+	if outTriple1.Subj.String() != s1 {
+		t.Error("Subject of first triple is wrong")
+	}
+	if outTriple1.Pred.String() != p1 {
+		t.Error("Predicate of first triple is wrong")
+	}
+	if outTriple1.Obj.String() != o1 {
+		t.Error("Object of first triple is wrong")
+	}
+	if outTriple2.Subj.String() != s2 {
+		t.Error("Subject of second triple is wrong")
+	}
+	if outTriple2.Pred.String() != p2 {
+		t.Error("Predicate of second triple is wrong")
+	}
+	if outTriple2.Obj.String() != o2 {
+		t.Error("Object of second triple is wrong")
+	}
+}
+
+// Tests that RDF/XML and N-Quads files are detected by extension and read
+func TestRDFFileReaderFormats(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testFiles := map[string]string{
+		"onto.owl": `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example.org/">
+  <rdf:Description rdf:about="http://example.org/s1">
+    <ex:p1>string1</ex:p1>
+  </rdf:Description>
+</rdf:RDF>`,
+		"data.nq": `<http://example.org/s1> <http://example.org/p1> "string1" <http://example.org/g1> .`,
+		"data.jsonld": `{
+  "@context": {"ex": "http://example.org/", "p1": "ex:p1"},
+  "@id": "ex:s1",
+  "p1": "string1"
+}`,
+	}
+
+	fs := afero.NewMemMapFs()
+	for fileName, content := range testFiles {
+		afero.WriteFile(fs, fileName, []byte(content), 0644)
+
+		fr := NewRDFFileReader(fs)
+		go func() {
+			defer close(fr.InFileName)
+			fr.InFileName <- fileName
+		}()
+		go fr.Run()
+
+		outTriple := <-fr.OutTriple
+		if outTriple.Subj.String() != "http://example.org/s1" {
+			t.Errorf("Subject of triple in %s is wrong: %s", fileName, outTriple.Subj.String())
+		}
+		if outTriple.Pred.String() != "http://example.org/p1" {
+			t.Errorf("Predicate of triple in %s is wrong: %s", fileName, outTriple.Pred.String())
+		}
+		if outTriple.Obj.String() != "string1" {
+			t.Errorf("Object of triple in %s is wrong: %s", fileName, outTriple.Obj.String())
+		}
+	}
+}
+
+// TestDetectRDFFormat tests DetectRDFFormat and ParseRDFFormat
+func TestDetectRDFFormat(t *testing.T) {
+	expected := map[string]string{
+		"onto.owl":     RDFFormatRDFXML,
+		"data.NT":      RDFFormatNTriples,
+		"data.ttl":     RDFFormatTurtle,
+		"data.nq":      RDFFormatNQuads,
+		"data.trig.gz": RDFFormatTriG,
+		"data.jsonld":  RDFFormatJSONLD,
+		"data.unknown": RDFFormatTurtle,
+	}
+	for fileName, format := range expected {
+		if detected := DetectRDFFormat(fileName); detected != format {
+			t.Errorf("Wrong format detected for %s: %s (expected %s)", fileName, detected, format)
+		}
+		if _, err := ParseRDFFormat(format); err != nil {
+			t.Errorf("Could not parse format name %s: %s", format, err.Error())
+		}
+	}
+	if _, err := ParseRDFFormat("n3000"); err == nil {
+		t.Error("No error for unknown format name")
+	}
+}
+
+// TestRDFFileReaderJSONLDError tests that a JSON-LD document that can not be
+// converted is reported as an error
+func TestRDFFileReaderJSONLDError(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "broken.jsonld", []byte(`{"@context": {"ex": "http://example.org/"}, "@id": "ex:s1", `), 0644)
+
+	fr := NewRDFFileReader(fs)
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- "broken.jsonld"
+	}()
+	go fr.Run()
+
+	errs := []*ConversionError{}
+	done := make(chan bool)
+	go func() {
+		for err := range fr.OutError {
+			errs = append(errs, err)
+		}
+		done <- true
+	}()
+	for range fr.OutTriple {
+		t.Error("Got triple from broken JSON-LD")
+	}
+	<-done
+	if len(errs) != 1 || errs[0].FileName != "broken.jsonld" {
+		t.Errorf("Expected one error for the JSON-LD file, got: %v", errs)
+	}
+}
+
+// Tests that blank nodes from different files are kept apart
+func TestRDFFileReaderBlankNodes(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "a.nt", []byte(`_:b0 <http://example.org/p1> "a" .`), 0644)
+	afero.WriteFile(fs, "b.nt", []byte(`_:b0 <http://example.org/p1> "b" .`), 0644)
+
+	fr := NewRDFFileReader(fs)
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- "a.nt"
+		fr.InFileName <- "b.nt"
+	}()
+	go fr.Run()
+
+	outTriple1 := <-fr.OutTriple
+	outTriple2 := <-fr.OutTriple
+	if outTriple1.Subj.String() != "b0" {
+		t.Errorf("Blank node in first file was renamed: %s", outTriple1.Subj.String())
+	}
+	if outTriple1.Subj.String() == outTriple2.Subj.String() {
+		t.Error("Blank nodes from different files were given the same label")
+	}
+}
+
+// Tests that the file name "-" reads from standard input
+func TestRDFFileReaderStdin(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fr := NewRDFFileReader(afero.NewMemMapFs())
+	fr.stdin = strings.NewReader(`<http://example.org/s1> <http://example.org/p1> "string1" .`)
+	go func() {
+		defer close(fr.InFileName)
+		fr.InFileName <- StdioFileName
+	}()
+	go fr.Run()
+
+	outTriple := <-fr.OutTriple
+	if outTriple.Obj.String() != "string1" {
+		t.Errorf("Wrong object read from standard input: %s", outTriple.Obj.String())
+	}
+}
+
+// TestRDFFileReaderStop tests that the RDFFileReader stops reading when its
+// Stop channel is closed, while still receiving file names
+func TestRDFFileReaderStop(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "a.nt", []byte("<http://example.org/s1> <http://example.org/p1> \"o1\" .\n"), 0644)
+	stop := make(chan struct{})
+	close(stop)
+	fr := NewRDFFileReader(fs)
+	fr.Stop = stop
+	go func() {
+		defer close(fr.InFileName)
+		for i := 0; i < 2*BUFSIZE; i++ {
+			fr.InFileName <- "a.nt"
+		}
+	}()
+	go fr.Run()
+
+	for tr := range fr.OutTriple {
+		t.Errorf("Triple read after stop: %v", tr)
+	}
+}
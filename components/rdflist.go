@@ -0,0 +1,124 @@
+package components
+
+import (
+	"sort"
+	"strconv"
+	str "strings"
+
+	"github.com/knakk/rdf"
+)
+
+const (
+	rdfNS       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfFirstURI = rdfNS + "first"
+	rdfRestURI  = rdfNS + "rest"
+	rdfNilURI   = rdfNS + "nil"
+)
+
+// containerTypes are the classes of RDF containers, whose members are given
+// by the properties rdf:_1, rdf:_2, etc.
+var containerTypes = []string{rdfNS + "Seq", rdfNS + "Bag", rdfNS + "Alt"}
+
+// collectionMembers returns the members of the collection or container obj,
+// in order, if obj is the empty list (rdf:nil), or a blank node starting a
+// list or typed as a container.
+func (p *TripleAggregateToWikiPageConverter) collectionMembers(obj rdf.Object, resourceIndex ResourceIndex) ([]rdf.Object, bool) {
+	if obj.Type() == rdf.TermIRI && obj.String() == rdfNilURI {
+		return []rdf.Object{}, true
+	}
+	if obj.Type() != rdf.TermBlank {
+		return nil, false
+	}
+	if members, ok := p.readList(obj.String(), resourceIndex); ok {
+		return members, true
+	}
+	return p.readContainer(obj.String(), resourceIndex)
+}
+
+// isCollectionNode tells whether aggr is a node of a list, or a container.
+func isCollectionNode(aggr *TripleAggregate) bool {
+	for _, tr := range aggr.Triples {
+		if tr.Pred.String() == rdfFirstURI {
+			return true
+		}
+	}
+	return isContainer(aggr)
+}
+
+// isContainer tells whether aggr is typed as a container.
+func isContainer(aggr *TripleAggregate) bool {
+	for _, tr := range aggr.Triples {
+		if tr.Pred.String() == typePropertyURI && containsString(containerTypes, tr.Obj.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// readList returns the members of the RDF collection (rdf:first / rdf:rest
+// list) starting at the node head, looking up the list nodes in
+// resourceIndex. The second return value is false if head is not a
+// well-formed list.
+func (p *TripleAggregateToWikiPageConverter) readList(head string, resourceIndex ResourceIndex) ([]rdf.Object, bool) {
+	members := []rdf.Object{}
+	seen := make(map[string]bool)
+	for node := head; node != rdfNilURI; {
+		if seen[node] {
+			return nil, false
+		}
+		seen[node] = true
+		aggr := p.getAggregate(resourceIndex, node)
+		if aggr == nil {
+			return nil, false
+		}
+		var first rdf.Object
+		rest := ""
+		for _, tr := range aggr.Triples {
+			switch tr.Pred.String() {
+			case rdfFirstURI:
+				first = tr.Obj
+			case rdfRestURI:
+				rest = tr.Obj.String()
+			}
+		}
+		if first == nil || rest == "" {
+			return nil, false
+		}
+		members = append(members, first)
+		node = rest
+	}
+	return members, true
+}
+
+// readContainer returns the members of the container (rdf:Seq, rdf:Bag or
+// rdf:Alt) node, ordered by their membership properties (rdf:_1, rdf:_2,
+// etc.). The second return value is false if node is not a container.
+func (p *TripleAggregateToWikiPageConverter) readContainer(node string, resourceIndex ResourceIndex) ([]rdf.Object, bool) {
+	aggr := p.getAggregate(resourceIndex, node)
+	if aggr == nil || !isContainer(aggr) {
+		return nil, false
+	}
+	type member struct {
+		index int
+		obj   rdf.Object
+	}
+	members := []member{}
+	for _, tr := range aggr.Triples {
+		if !str.HasPrefix(tr.Pred.String(), rdfNS+"_") {
+			continue
+		}
+		index, err := strconv.Atoi(str.TrimPrefix(tr.Pred.String(), rdfNS+"_"))
+		if err != nil || index < 1 {
+			continue
+		}
+		members = append(members, member{index, tr.Obj})
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].index < members[j].index
+	})
+	objs := []rdf.Object{}
+	for _, m := range members {
+		objs = append(objs, m.obj)
+	}
+	return objs, true
+}
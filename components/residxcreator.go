@@ -1,24 +1,64 @@
 package components
 
+import (
+	"fmt"
+
+	"github.com/knakk/rdf"
+)
+
+// ResourceIndexCreator is a process that adds all triples it receives to a
+// ResourceIndex, and sends the index on its Out port once all triples are
+// added. Triples that can not be added are reported as *ConversionError's on
+// the OutError port.
+//
+// If Predicates is set, only triples with one of those predicates are added,
+// which is used to build a small lookup index of titles and types only (see
+// Config.LookupPredicates). Triples with a blank node as subject or object are
+// always added, since blank nodes are converted together with the resources
+// referencing them.
 type ResourceIndexCreator struct {
-	In  chan *TripleAggregate
-	Out chan *map[string]*TripleAggregate
+	In         chan rdf.Triple
+	Out        chan ResourceIndex
+	OutError   chan *ConversionError
+	Predicates []string
+	index      ResourceIndex
 }
 
-func NewResourceIndexCreator() *ResourceIndexCreator {
+// NewResourceIndexCreator returns an initialized ResourceIndexCreator,
+// adding triples to the (empty) index provided as an argument.
+func NewResourceIndexCreator(index ResourceIndex) *ResourceIndexCreator {
 	return &ResourceIndexCreator{
-		In:  make(chan *TripleAggregate, BUFSIZE),
-		Out: make(chan *map[string]*TripleAggregate),
+		In:       make(chan rdf.Triple, BUFSIZE),
+		Out:      make(chan ResourceIndex),
+		OutError: make(chan *ConversionError, BUFSIZE),
+		index:    index,
 	}
 }
 
 func (p *ResourceIndexCreator) Run() {
 	defer close(p.Out)
+	defer close(p.OutError)
+
+	keepPreds := make(map[string]bool)
+	for _, pred := range p.Predicates {
+		keepPreds[pred] = true
+	}
 
-	idx := make(map[string]*TripleAggregate)
-	for aggr := range p.In {
-		idx[aggr.SubjectStr] = aggr
+	for triple := range p.In {
+		if len(keepPreds) > 0 && !keepPreds[triple.Pred.String()] && !hasBlankNode(triple) {
+			continue
+		}
+		if err := p.index.Add(triple); err != nil {
+			p.OutError <- NewConversionError("", 0, triple.Serialize(rdf.NTriples), fmt.Errorf("could not add triple to index: %s", err.Error()))
+		}
 	}
+	if err := p.index.Flush(); err != nil {
+		p.OutError <- NewConversionError("", 0, "", fmt.Errorf("could not write index: %s", err.Error()))
+	}
+
+	p.Out <- p.index
+}
 
-	p.Out <- &idx
+func hasBlankNode(triple rdf.Triple) bool {
+	return triple.Subj.Type() == rdf.TermBlank || triple.Obj.Type() == rdf.TermBlank
 }
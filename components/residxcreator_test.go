@@ -11,7 +11,7 @@ import (
 func TestNewResourceIndexCreator(t *testing.T) {
 	flowbase.InitLogWarning()
 
-	ric := NewResourceIndexCreator()
+	ric := NewResourceIndexCreator(NewMemResourceIndex())
 
 	if ric.In == nil {
 		t.Error("In-port not initialized")
@@ -24,16 +24,13 @@ func TestNewResourceIndexCreator(t *testing.T) {
 func TestResourceIndexCreator(t *testing.T) {
 	flowbase.InitLogWarning()
 
-	ric := NewResourceIndexCreator()
-
-	var triples = []rdf.Triple{}
+	ric := NewResourceIndexCreator(NewMemResourceIndex())
 
 	go func() {
 		defer close(ric.In)
 
 		for i := 1; i <= 2; i++ {
 
-			triples = []rdf.Triple{}
 			s, serr := rdf.NewIRI(fmt.Sprintf("http://example.org/s%d", i))
 			if serr != nil {
 				t.Error("Could not create Subject IRI")
@@ -52,11 +49,8 @@ func TestResourceIndexCreator(t *testing.T) {
 					Pred: p,
 					Obj:  o,
 				}
-				triples = append(triples, tr)
+				ric.In <- tr
 			}
-
-			aggr := NewTripleAggregate(s, triples)
-			ric.In <- aggr
 		}
 	}()
 
@@ -64,27 +58,51 @@ func TestResourceIndexCreator(t *testing.T) {
 
 	resIdx := <-ric.Out
 
-	if (*resIdx)["http://example.org/s1"] == nil {
-		t.Error("Resource index does not contain first subject")
-	}
+	for _, subj := range []string{"http://example.org/s1", "http://example.org/s2"} {
+		aggr, _ := resIdx.Get(subj)
+		if aggr == nil {
+			t.Fatalf("Resource index does not contain subject %s", subj)
+		}
 
-	if (*resIdx)["http://example.org/s1"].Subject.String() != "http://example.org/s1" {
-		t.Error("Subject string in first subject is wrong")
-	}
+		if aggr.Subject.String() != subj {
+			t.Errorf("Subject string in subject %s is wrong", subj)
+		}
 
-	if len((*resIdx)["http://example.org/s1"].Triples) != 3 {
-		t.Error("Wrong number of triples for first subject")
+		if len(aggr.Triples) != 3 {
+			t.Errorf("Wrong number of triples for subject %s", subj)
+		}
 	}
+}
 
-	if (*resIdx)["http://example.org/s2"] == nil {
-		t.Error("Resource index does not contain second subject")
-	}
+func TestResourceIndexCreatorPredicates(t *testing.T) {
+	flowbase.InitLogWarning()
 
-	if (*resIdx)["http://example.org/s2"].Subject.String() != "http://example.org/s2" {
-		t.Error("Subject string in second subject is wrong")
-	}
+	ric := NewResourceIndexCreator(NewMemResourceIndex())
+	ric.Predicates = []string{"http://example.org/p2"}
+
+	go func() {
+		defer close(ric.In)
+		s, _ := rdf.NewIRI("http://example.org/s1")
+		for j := 1; j <= 3; j++ {
+			p, _ := rdf.NewIRI(fmt.Sprintf("http://example.org/p%d", j))
+			o, _ := rdf.NewLiteral(fmt.Sprintf("o%d", j))
+			ric.In <- rdf.Triple{Subj: s, Pred: p, Obj: o}
+		}
+	}()
+	go func() {
+		for range ric.OutError {
+		}
+	}()
+
+	go ric.Run()
 
-	if len((*resIdx)["http://example.org/s2"].Triples) != 3 {
-		t.Error("Wrong number of triples for second subject")
+	resIdx := <-ric.Out
+
+	aggr, _ := resIdx.Get("http://example.org/s1")
+	if aggr == nil || len(aggr.Triples) != 1 {
+		t.Fatal("Expected exactly one triple to be indexed for the first subject")
+	}
+	if aggr.Triples[0].Pred.String() != "http://example.org/p2" {
+		t.Errorf("Wrong triple indexed: %s", aggr.Triples[0].Serialize(rdf.NTriples))
 	}
 }
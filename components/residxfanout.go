@@ -1,14 +1,16 @@
 package components
 
+import "sort"
+
 type ResourceIndexFanOut struct {
-	In  chan *map[string]*TripleAggregate
-	Out map[string]chan *map[string]*TripleAggregate
+	In  chan ResourceIndex
+	Out map[string]chan ResourceIndex
 }
 
 func NewResourceIndexFanOut() *ResourceIndexFanOut {
 	return &ResourceIndexFanOut{
-		In:  make(chan *map[string]*TripleAggregate),
-		Out: make(map[string]chan *map[string]*TripleAggregate),
+		In:  make(chan ResourceIndex),
+		Out: make(map[string]chan ResourceIndex),
 	}
 }
 
@@ -17,9 +19,17 @@ func (p *ResourceIndexFanOut) Run() {
 		defer close(outPort)
 	}
 
+	// Send to the out-ports in a fixed order, so that unbuffered out-ports
+	// can be read one after the other by the receiver
+	portNames := []string{}
+	for portName := range p.Out {
+		portNames = append(portNames, portName)
+	}
+	sort.Strings(portNames)
+
 	for idx := range p.In {
-		for _, outPort := range p.Out {
-			outPort <- idx
+		for _, portName := range portNames {
+			p.Out[portName] <- idx
 		}
 	}
 }
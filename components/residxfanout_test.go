@@ -23,11 +23,10 @@ func TestResourceIndexFanOut(t *testing.T) {
 	flowbase.InitLogDebug()
 
 	rif := NewResourceIndexFanOut()
-	rif.Out["out1"] = make(chan *map[string]*TripleAggregate)
-	rif.Out["out2"] = make(chan *map[string]*TripleAggregate)
+	rif.Out["out1"] = make(chan ResourceIndex)
+	rif.Out["out2"] = make(chan ResourceIndex)
 
-	resIdxInner := make(map[string]*TripleAggregate)
-	resIdx := &resIdxInner
+	resIdx := NewMemResourceIndex()
 
 	go func() {
 		defer close(rif.In)
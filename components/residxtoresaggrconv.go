@@ -1,13 +1,13 @@
 package components
 
 type ResourceIndexToTripleAggregates struct {
-	In  chan *map[string]*TripleAggregate
+	In  chan ResourceIndex
 	Out chan *TripleAggregate
 }
 
 func NewResourceIndexToTripleAggregates() *ResourceIndexToTripleAggregates {
 	return &ResourceIndexToTripleAggregates{
-		In:  make(chan *map[string]*TripleAggregate, BUFSIZE),
+		In:  make(chan ResourceIndex, BUFSIZE),
 		Out: make(chan *TripleAggregate, BUFSIZE),
 	}
 }
@@ -16,8 +16,12 @@ func (p *ResourceIndexToTripleAggregates) Run() {
 	defer close(p.Out)
 
 	for idx := range p.In {
-		for _, aggr := range *idx {
-			p.Out <- aggr
-		}
+		// Errors of reading aggregates are reported by the converter, which
+		// reads every aggregate of the same index
+		idx.Each(func(aggr *TripleAggregate, err error) {
+			if aggr != nil {
+				p.Out <- aggr
+			}
+		})
 	}
 }
@@ -25,13 +25,20 @@ func TestResourceIndexToTripleAggregates(t *testing.T) {
 	flowbase.InitLogDebug()
 	rita := NewResourceIndexToTripleAggregates()
 
-	resIdxInner := make(map[string]*TripleAggregate)
+	resIdx := NewMemResourceIndex()
 	s, err := rdf.NewIRI("http://example.org/s")
 	if err != nil {
 		t.Error("Could not create subject IRI")
 	}
-	resIdxInner["aggr1"] = NewTripleAggregate(s, nil)
-	resIdx := &resIdxInner
+	p, err := rdf.NewIRI("http://example.org/p")
+	if err != nil {
+		t.Error("Could not create predicate IRI")
+	}
+	o, err := rdf.NewLiteral("o")
+	if err != nil {
+		t.Error("Could not create object literal")
+	}
+	resIdx.Add(rdf.Triple{Subj: s, Pred: p, Obj: o})
 
 	go func() {
 		defer close(rita.In)
@@ -0,0 +1,345 @@
+package components
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	str "strings"
+
+	"github.com/knakk/rdf"
+	bolt "go.etcd.io/bbolt"
+)
+
+// --------------------------------------------------------------------------------
+// ResourceIndex
+// --------------------------------------------------------------------------------
+
+// ResourceIndex is an index of all triples, aggregated per subject, and
+// indexed by the subject URI (or blank node label).
+type ResourceIndex interface {
+	// Add adds a triple to the aggregate of its subject, and counts it as a
+	// reference to its object, if the object is a blank node
+	Add(triple rdf.Triple) error
+	// Flush makes all added triples available for reading. It has to be
+	// called after the last call to Add, and before any calls to Get or Each,
+	// which may then be called concurrently.
+	Flush() error
+	// Get returns the aggregate of the subject subjectStr, or nil if the
+	// subject is not in the index. If some of the triples of the subject can
+	// not be read back, the error is returned together with the aggregate of
+	// the other triples (or nil, if there are none).
+	Get(subjectStr string) (*TripleAggregate, error)
+	// Each calls fn for every subject in the index, with its aggregate, and
+	// the error of reading it, as returned by Get
+	Each(fn func(aggr *TripleAggregate, err error))
+	// References returns the number of triples added with the blank node
+	// blankLabel as object
+	References(blankLabel string) int
+	// Len returns the number of subjects in the index
+	Len() int
+	// Close releases any resources held by the index
+	Close() error
+}
+
+// Names of the resource index backends understood by NewResourceIndex.
+const (
+	IndexBackendMemory = "memory"
+	IndexBackendDisk   = "disk"
+)
+
+// NewResourceIndex returns a new, empty, ResourceIndex of the backend type
+// backend. For the disk backend, the index file is created in the directory
+// dir, or in the default temp directory if dir is empty.
+func NewResourceIndex(backend string, dir string) (ResourceIndex, error) {
+	switch backend {
+	case IndexBackendMemory:
+		return NewMemResourceIndex(), nil
+	case IndexBackendDisk:
+		return NewDiskResourceIndex(dir)
+	}
+	return nil, fmt.Errorf("unknown index backend %q (expected one of: %s, %s)", backend, IndexBackendMemory, IndexBackendDisk)
+}
+
+// --------------------------------------------------------------------------------
+// MemResourceIndex
+// --------------------------------------------------------------------------------
+
+// MemResourceIndex is a ResourceIndex keeping all triples in memory.
+type MemResourceIndex struct {
+	aggrs map[string]*TripleAggregate
+	refs  map[string]int
+}
+
+// NewMemResourceIndex returns a new, empty, MemResourceIndex.
+func NewMemResourceIndex() *MemResourceIndex {
+	return &MemResourceIndex{
+		aggrs: make(map[string]*TripleAggregate),
+		refs:  make(map[string]int),
+	}
+}
+
+func (idx *MemResourceIndex) Add(triple rdf.Triple) error {
+	subjStr := triple.Subj.String()
+	if aggr, ok := idx.aggrs[subjStr]; ok {
+		aggr.Triples = append(aggr.Triples, triple)
+	} else {
+		idx.aggrs[subjStr] = NewTripleAggregate(triple.Subj, []rdf.Triple{triple})
+	}
+	if obj, ok := triple.Obj.(rdf.Blank); ok {
+		idx.refs[obj.String()]++
+	}
+	return nil
+}
+
+func (idx *MemResourceIndex) Flush() error {
+	return nil
+}
+
+func (idx *MemResourceIndex) Get(subjectStr string) (*TripleAggregate, error) {
+	return idx.aggrs[subjectStr], nil
+}
+
+func (idx *MemResourceIndex) Each(fn func(aggr *TripleAggregate, err error)) {
+	for _, aggr := range idx.aggrs {
+		fn(aggr, nil)
+	}
+}
+
+func (idx *MemResourceIndex) References(blankLabel string) int {
+	return idx.refs[blankLabel]
+}
+
+func (idx *MemResourceIndex) Len() int {
+	return len(idx.aggrs)
+}
+
+func (idx *MemResourceIndex) Close() error {
+	return nil
+}
+
+// --------------------------------------------------------------------------------
+// DiskResourceIndex
+// --------------------------------------------------------------------------------
+
+// diskIndexBatchSize is the number of triples buffered in memory by the
+// DiskResourceIndex before they are written to disk in one transaction.
+const diskIndexBatchSize = 100000
+
+var (
+	diskIndexBucket     = []byte("aggregates")
+	diskReferenceBucket = []byte("references")
+)
+
+// DiskResourceIndex is a ResourceIndex keeping all triples in an embedded
+// key-value store on disk (bbolt), for datasets that do not fit in memory.
+// Triples are stored as N-Triples, keyed by subject and the sequence number
+// of the batch they were written in, so that flushing a batch never rewrites
+// what was stored before. The keys of a subject sort together and in batch
+// order, and are merged when read. The index file is
+// unlinked right after it is opened where the OS allows it, so that it is
+// cleaned up even if the program is stopped, and otherwise removed when the
+// index is closed.
+type DiskResourceIndex struct {
+	db        *bolt.DB
+	fileName  string
+	batch     map[string][]string
+	batchRefs map[string]int
+	batchLen  int
+	batchSeq  uint64
+	subjCnt   int
+}
+
+// NewDiskResourceIndex returns a new, empty, DiskResourceIndex, with its
+// index file in the directory dir (or the default temp directory if empty).
+func NewDiskResourceIndex(dir string) (*DiskResourceIndex, error) {
+	tmpDir, err := os.MkdirTemp(dir, "rdf2smw-index-")
+	if err != nil {
+		return nil, err
+	}
+	fileName := filepath.Join(tmpDir, "index.db")
+	db, err := bolt.Open(fileName, 0600, &bolt.Options{NoSync: true, NoFreelistSync: true})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(diskIndexBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(diskReferenceBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	os.RemoveAll(tmpDir)
+	return &DiskResourceIndex{
+		db:        db,
+		fileName:  fileName,
+		batch:     make(map[string][]string),
+		batchRefs: make(map[string]int),
+	}, nil
+}
+
+func (idx *DiskResourceIndex) Add(triple rdf.Triple) error {
+	subjStr := triple.Subj.String()
+	idx.batch[subjStr] = append(idx.batch[subjStr], triple.Serialize(rdf.NTriples))
+	if obj, ok := triple.Obj.(rdf.Blank); ok {
+		idx.batchRefs[obj.String()]++
+	}
+	idx.batchLen++
+	if idx.batchLen >= diskIndexBatchSize {
+		return idx.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered triples to disk, under keys of their own, next
+// to any triples already stored for the same subjects, and adds up reference
+// counts.
+func (idx *DiskResourceIndex) Flush() error {
+	if idx.batchLen == 0 {
+		return nil
+	}
+	idx.batchSeq++
+	err := idx.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(diskIndexBucket)
+		c := b.Cursor()
+		for subjStr, lines := range idx.batch {
+			prefix := diskIndexKeyPrefix(subjStr)
+			if key, _ := c.Seek(prefix); !bytes.HasPrefix(key, prefix) {
+				idx.subjCnt++
+			}
+			key := binary.BigEndian.AppendUint64(prefix, idx.batchSeq)
+			if err := b.Put(key, []byte(str.Join(lines, ""))); err != nil {
+				return err
+			}
+		}
+		refBucket := tx.Bucket(diskReferenceBucket)
+		for label, cnt := range idx.batchRefs {
+			key := []byte(label)
+			if oldVal := refBucket.Get(key); oldVal != nil {
+				oldCnt, _ := strconv.Atoi(string(oldVal))
+				cnt += oldCnt
+			}
+			if err := refBucket.Put(key, []byte(strconv.Itoa(cnt))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	idx.batch = make(map[string][]string)
+	idx.batchRefs = make(map[string]int)
+	idx.batchLen = 0
+	return err
+}
+
+func (idx *DiskResourceIndex) Get(subjectStr string) (*TripleAggregate, error) {
+	var aggr *TripleAggregate
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		prefix := diskIndexKeyPrefix(subjectStr)
+		val := []byte{}
+		c := tx.Bucket(diskIndexBucket).Cursor()
+		for key, batchVal := c.Seek(prefix); bytes.HasPrefix(key, prefix); key, batchVal = c.Next() {
+			val = append(val, batchVal...)
+		}
+		if len(val) == 0 {
+			return nil
+		}
+		var err error
+		aggr, err = decodeAggregate(subjectStr, val)
+		return err
+	})
+	return aggr, err
+}
+
+func (idx *DiskResourceIndex) Each(fn func(aggr *TripleAggregate, err error)) {
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		var prefix []byte
+		val := []byte{}
+		emit := func() {
+			fn(decodeAggregate(string(prefix[:len(prefix)-1]), val))
+		}
+		c := tx.Bucket(diskIndexBucket).Cursor()
+		for key, batchVal := c.First(); key != nil; key, batchVal = c.Next() {
+			if prefix == nil || !bytes.HasPrefix(key, prefix) {
+				if prefix != nil {
+					emit()
+				}
+				prefix = key[:len(key)-8]
+				val = val[:0]
+			}
+			val = append(val, batchVal...)
+		}
+		if prefix != nil {
+			emit()
+		}
+		return nil
+	})
+	if err != nil {
+		fn(nil, err)
+	}
+}
+
+func (idx *DiskResourceIndex) References(blankLabel string) int {
+	cnt := 0
+	idx.db.View(func(tx *bolt.Tx) error {
+		if val := tx.Bucket(diskReferenceBucket).Get([]byte(blankLabel)); val != nil {
+			cnt, _ = strconv.Atoi(string(val))
+		}
+		return nil
+	})
+	return cnt
+}
+
+func (idx *DiskResourceIndex) Len() int {
+	return idx.subjCnt
+}
+
+func (idx *DiskResourceIndex) Close() error {
+	err := idx.db.Close()
+	os.RemoveAll(filepath.Dir(idx.fileName))
+	return err
+}
+
+// diskIndexKeyPrefix returns the part of the keys of the subject subjStr
+// before the batch sequence number. The subject is followed by a NUL byte,
+// which can not occur in IRIs or blank node labels, so that the keys of one
+// subject are not mixed up with those of subjects it is a prefix of.
+func diskIndexKeyPrefix(subjStr string) []byte {
+	return append([]byte(subjStr), 0)
+}
+
+// decodeAggregate decodes N-Triples stored for the subject subjStr into a
+// TripleAggregate. Triples that can not be decoded are skipped, and the
+// first error returned together with the aggregate of the others, or nil if
+// there are none.
+func decodeAggregate(subjStr string, val []byte) (*TripleAggregate, error) {
+	triples := []rdf.Triple{}
+	var firstErr error
+	failed := 0
+	dec := rdf.NewTripleDecoder(str.NewReader(string(val)), rdf.NTriples)
+	for triple, err := dec.Decode(); err != io.EOF; triple, err = dec.Decode() {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		triples = append(triples, triple)
+	}
+	if firstErr != nil {
+		firstErr = fmt.Errorf("could not read %d triple(s) of %s from index: %s", failed, subjStr, firstErr.Error())
+	}
+	if len(triples) == 0 {
+		return nil, firstErr
+	}
+	return NewTripleAggregate(triples[0].Subj, triples), firstErr
+}
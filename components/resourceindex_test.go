@@ -0,0 +1,211 @@
+package components
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/knakk/rdf"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TestResourceIndexBackends tests that all index backends aggregate and
+// return triples in the same way
+func TestResourceIndexBackends(t *testing.T) {
+	testData := `
+<http://example.org/s1> <http://example.org/p1> "o1" .
+<http://example.org/s2> <http://example.org/p1> "o2"@en .
+<http://example.org/s1> <http://example.org/p2> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/s1> <http://example.org/p3> <http://example.org/s2> .
+_:b1 <http://example.org/p1> "line one\nline two" .
+<http://example.org/s2> <http://example.org/p3> _:b1 .
+`
+	triples, err := rdf.NewTripleDecoder(strings.NewReader(testData), rdf.NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal("Could not decode test data: ", err.Error())
+	}
+
+	for _, backend := range []string{IndexBackendMemory, IndexBackendDisk} {
+		idx, err := NewResourceIndex(backend, t.TempDir())
+		if err != nil {
+			t.Fatalf("Could not create %s index: %s", backend, err.Error())
+		}
+		for _, tr := range triples {
+			if err := idx.Add(tr); err != nil {
+				t.Errorf("Could not add triple to %s index: %s", backend, err.Error())
+			}
+		}
+		if err := idx.Flush(); err != nil {
+			t.Errorf("Could not flush %s index: %s", backend, err.Error())
+		}
+
+		if idx.Len() != 3 {
+			t.Errorf("Wrong number of subjects in %s index: %d", backend, idx.Len())
+		}
+
+		aggr, err := idx.Get("http://example.org/s1")
+		if err != nil {
+			t.Errorf("Could not read s1 from %s index: %s", backend, err.Error())
+		}
+		if aggr == nil {
+			t.Fatalf("Subject s1 missing from %s index", backend)
+		}
+		if aggr.SubjectStr != "http://example.org/s1" || len(aggr.Triples) != 3 {
+			t.Errorf("Wrong aggregate for s1 in %s index: %v", backend, aggr)
+		}
+		if aggr.Triples[1].Obj.(rdf.Literal).DataType.String() != dataTypeURIInteger {
+			t.Errorf("Datatype of literal not kept in %s index", backend)
+		}
+		if aggr.Triples[2].Obj.Type() != rdf.TermIRI {
+			t.Errorf("IRI object not kept in %s index", backend)
+		}
+		if s2Aggr, _ := idx.Get("http://example.org/s2"); s2Aggr.Triples[0].Obj.(rdf.Literal).Lang() != "en" {
+			t.Errorf("Language tag not kept in %s index", backend)
+		}
+		if blankAggr, _ := idx.Get("b1"); blankAggr == nil || blankAggr.Triples[0].Obj.String() != "line one\nline two" {
+			t.Errorf("Blank node subject or multi-line literal not kept in %s index", backend)
+		}
+		if refs := idx.References("b1"); refs != 1 {
+			t.Errorf("Wrong number of references to blank node in %s index: %d", backend, refs)
+		}
+		if idx.References("b2") != 0 {
+			t.Errorf("Got references to missing blank node from %s index", backend)
+		}
+		if missing, err := idx.Get("http://example.org/missing"); missing != nil || err != nil {
+			t.Errorf("Got aggregate for missing subject from %s index", backend)
+		}
+
+		cnt := 0
+		idx.Each(func(aggr *TripleAggregate, err error) {
+			if err != nil {
+				t.Errorf("Could not read %s from %s index: %s", aggr.SubjectStr, backend, err.Error())
+			}
+			cnt++
+		})
+		if cnt != 3 {
+			t.Errorf("Each iterated over %d aggregates in %s index, expected 3", cnt, backend)
+		}
+
+		if err := idx.Close(); err != nil {
+			t.Errorf("Could not close %s index: %s", backend, err.Error())
+		}
+	}
+
+	if _, err := NewResourceIndex("cloud", ""); err == nil {
+		t.Error("No error for unknown index backend")
+	}
+}
+
+// TestDiskResourceIndexBatches tests that the triples of a subject written in
+// several batches are merged in order, also for subjects that are prefixes
+// of each other
+func TestDiskResourceIndexBatches(t *testing.T) {
+	idx, err := NewDiskResourceIndex(t.TempDir())
+	if err != nil {
+		t.Fatal("Could not create disk index: ", err.Error())
+	}
+	defer idx.Close()
+
+	subjects := []string{"http://example.org/s", "http://example.org/s1"}
+	for i := 0; i < 3; i++ {
+		for _, subj := range subjects {
+			s, _ := rdf.NewIRI(subj)
+			p, _ := rdf.NewIRI("http://example.org/p")
+			o, _ := rdf.NewLiteral(strconv.Itoa(i))
+			if err := idx.Add(rdf.Triple{Subj: s, Pred: p, Obj: o}); err != nil {
+				t.Error("Could not add triple to disk index: ", err.Error())
+			}
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal("Could not flush disk index: ", err.Error())
+		}
+	}
+
+	if idx.Len() != 2 {
+		t.Errorf("Wrong number of subjects in disk index: %d", idx.Len())
+	}
+	for _, subj := range subjects {
+		aggr, _ := idx.Get(subj)
+		if aggr == nil || len(aggr.Triples) != 3 {
+			t.Fatalf("Wrong aggregate for %s in disk index: %v", subj, aggr)
+		}
+		for i, tr := range aggr.Triples {
+			if tr.Obj.String() != strconv.Itoa(i) {
+				t.Errorf("Triples of %s out of order in disk index: %v", subj, aggr.Triples)
+			}
+		}
+	}
+	idx.Each(func(aggr *TripleAggregate, err error) {
+		if len(aggr.Triples) != 3 {
+			t.Errorf("Each gave %d triples for %s, expected 3", len(aggr.Triples), aggr.SubjectStr)
+		}
+	})
+}
+
+// TestDiskResourceIndexDecodeError tests that triples that can not be read
+// back from the disk index are reported, both by the index and the converter,
+// rather than silently dropped
+func TestDiskResourceIndexDecodeError(t *testing.T) {
+	idx, err := NewDiskResourceIndex(t.TempDir())
+	if err != nil {
+		t.Fatal("Could not create disk index: ", err.Error())
+	}
+	defer idx.Close()
+
+	s, _ := rdf.NewIRI("http://example.org/s")
+	p, _ := rdf.NewIRI("http://example.org/p")
+	o, _ := rdf.NewLiteral("o")
+	idx.Add(rdf.Triple{Subj: s, Pred: p, Obj: o})
+	if err := idx.Flush(); err != nil {
+		t.Fatal("Could not flush disk index: ", err.Error())
+	}
+	// Store a broken line next to the one written by the index
+	err = idx.db.Update(func(tx *bolt.Tx) error {
+		key := binary.BigEndian.AppendUint64(diskIndexKeyPrefix("http://example.org/s"), idx.batchSeq+1)
+		return tx.Bucket(diskIndexBucket).Put(key, []byte("<http://example.org/s> <http://example.org/p> \"broken .\n"))
+	})
+	if err != nil {
+		t.Fatal("Could not corrupt disk index: ", err.Error())
+	}
+
+	aggr, err := idx.Get("http://example.org/s")
+	if err == nil {
+		t.Error("No error for broken triple from Get")
+	}
+	if aggr == nil || len(aggr.Triples) != 1 {
+		t.Errorf("Expected the readable triple from Get, got: %v", aggr)
+	}
+	errCnt := 0
+	idx.Each(func(aggr *TripleAggregate, err error) {
+		if err != nil {
+			errCnt++
+		}
+	})
+	if errCnt != 1 {
+		t.Errorf("Expected one error from Each, got %d", errCnt)
+	}
+
+	conv := NewTripleAggregateToWikiPageConverter(DefaultConfig())
+	go func() {
+		conv.InIndex <- idx
+		close(conv.InIndex)
+		conv.InAggregate <- aggr
+		close(conv.InAggregate)
+	}()
+	go conv.Run()
+	convErrs := []*ConversionError{}
+	done := make(chan bool)
+	go func() {
+		for convErr := range conv.OutError {
+			convErrs = append(convErrs, convErr)
+		}
+		done <- true
+	}()
+	for range conv.OutPage {
+	}
+	<-done
+	if len(convErrs) != 1 || !strings.Contains(convErrs[0].Error(), "http://example.org/s") {
+		t.Errorf("Expected one conversion error for the subject, got: %v", convErrs)
+	}
+}
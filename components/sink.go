@@ -0,0 +1,28 @@
+package components
+
+// Sink is a process that drains all the done-signal ports connected to it,
+// and returns when all of them are closed. It replaces flowbase.Sink, which
+// can panic when several ports are closed in the same iteration.
+type Sink struct {
+	inPorts []chan interface{}
+}
+
+// NewSink returns an initialized Sink process.
+func NewSink() *Sink {
+	return &Sink{
+		inPorts: []chan interface{}{},
+	}
+}
+
+// Connect connects a done-signal port to the sink.
+func (p *Sink) Connect(ch chan interface{}) {
+	p.inPorts = append(p.inPorts, ch)
+}
+
+// Run runs the Sink process, until all connected ports are closed.
+func (p *Sink) Run() {
+	for _, inPort := range p.inPorts {
+		for range inPort {
+		}
+	}
+}
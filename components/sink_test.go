@@ -0,0 +1,28 @@
+package components
+
+import (
+	"testing"
+)
+
+// TestSink tests that the sink returns when all its ports are closed
+func TestSink(t *testing.T) {
+	snk := NewSink()
+	ports := []chan interface{}{}
+	for i := 0; i < 3; i++ {
+		ch := make(chan interface{}, BUFSIZE)
+		snk.Connect(ch)
+		ports = append(ports, ch)
+	}
+	for _, ch := range ports {
+		ch <- &DoneSignal{}
+		close(ch)
+	}
+
+	snk.Run()
+
+	for i, ch := range ports {
+		if _, ok := <-ch; ok {
+			t.Errorf("Port %d was not drained by the sink", i)
+		}
+	}
+}
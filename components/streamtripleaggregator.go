@@ -0,0 +1,80 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/knakk/rdf"
+)
+
+// StreamingTripleAggregator aggregates triples by subject into a
+// TripleAggregate object per subject, like TripleAggregator, but for input
+// that is already grouped (e.g. sorted) by subject. Each aggregate is sent as
+// soon as the subject changes, so only the triples of one subject are kept in
+// memory at a time.
+//
+// If a subject shows up again after other subjects, the input was not grouped
+// by subject, and this is reported as a *ConversionError on the OutError port
+// (the triples are still sent, as a separate aggregate). To keep memory use
+// bounded, this is only detected for subjects among the last
+// streamRecentSubjects ones.
+type StreamingTripleAggregator struct {
+	In       chan rdf.Triple
+	Out      chan *TripleAggregate
+	OutError chan *ConversionError
+	recent   int
+}
+
+// streamRecentSubjects is the number of subjects remembered by the
+// StreamingTripleAggregator, to detect input that is not grouped by subject.
+const streamRecentSubjects = 100000
+
+// NewStreamingTripleAggregator returns an initialized
+// StreamingTripleAggregator process.
+func NewStreamingTripleAggregator() *StreamingTripleAggregator {
+	return &StreamingTripleAggregator{
+		In:       make(chan rdf.Triple, BUFSIZE),
+		Out:      make(chan *TripleAggregate, BUFSIZE),
+		OutError: make(chan *ConversionError, BUFSIZE),
+		recent:   streamRecentSubjects,
+	}
+}
+
+// Run runs the StreamingTripleAggregator process.
+func (p *StreamingTripleAggregator) Run() {
+	defer close(p.Out)
+	defer close(p.OutError)
+
+	// Only the strings of the most recent subjects are kept, to detect input
+	// that is not grouped by subject, with the oldest one replaced in the
+	// ring recentRing when it is full
+	recentSubjects := make(map[string]bool)
+	recentRing := make([]string, 0, p.recent)
+	oldest := 0
+
+	var aggr *TripleAggregate
+	for triple := range p.In {
+		subjStr := triple.Subj.String()
+		if aggr != nil && subjStr == aggr.SubjectStr {
+			aggr.Triples = append(aggr.Triples, triple)
+			continue
+		}
+		if aggr != nil {
+			p.Out <- aggr
+		}
+		if recentSubjects[subjStr] {
+			p.OutError <- NewConversionError("", 0, subjStr, fmt.Errorf("subject appears again after other subjects: input is not sorted by subject"))
+		} else if len(recentRing) < p.recent {
+			recentRing = append(recentRing, subjStr)
+			recentSubjects[subjStr] = true
+		} else {
+			delete(recentSubjects, recentRing[oldest])
+			recentRing[oldest] = subjStr
+			recentSubjects[subjStr] = true
+			oldest = (oldest + 1) % p.recent
+		}
+		aggr = NewTripleAggregate(triple.Subj, []rdf.Triple{triple})
+	}
+	if aggr != nil {
+		p.Out <- aggr
+	}
+}
@@ -0,0 +1,115 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+	"github.com/knakk/rdf"
+)
+
+func TestStreamingTripleAggregator(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/s1> <http://example.org/p1> "o1" .
+<http://example.org/s1> <http://example.org/p2> "o2" .
+<http://example.org/s2> <http://example.org/p1> "o3" .
+<http://example.org/s3> <http://example.org/p1> "o4" .
+<http://example.org/s3> <http://example.org/p2> "o5" .
+<http://example.org/s1> <http://example.org/p3> "o6" .
+`
+	triples, err := rdf.NewTripleDecoder(strings.NewReader(testData), rdf.NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal("Could not decode n-triples test data")
+	}
+
+	aggregator := NewStreamingTripleAggregator()
+	go func() {
+		defer close(aggregator.In)
+		for _, tr := range triples {
+			aggregator.In <- tr
+		}
+	}()
+	go aggregator.Run()
+
+	errs := []*ConversionError{}
+	errsDone := make(chan bool)
+	go func() {
+		for err := range aggregator.OutError {
+			errs = append(errs, err)
+		}
+		errsDone <- true
+	}()
+
+	expected := []struct {
+		subj    string
+		triples int
+	}{
+		{"http://example.org/s1", 2},
+		{"http://example.org/s2", 1},
+		{"http://example.org/s3", 2},
+		{"http://example.org/s1", 1},
+	}
+	aggrs := []*TripleAggregate{}
+	for aggr := range aggregator.Out {
+		aggrs = append(aggrs, aggr)
+	}
+	<-errsDone
+
+	if len(aggrs) != len(expected) {
+		t.Fatalf("Wrong number of aggregates (Expected %d, got %d)", len(expected), len(aggrs))
+	}
+	for i, exp := range expected {
+		if aggrs[i].SubjectStr != exp.subj {
+			t.Errorf("Wrong subject of aggregate %d (Expected %s, got %s)", i, exp.subj, aggrs[i].SubjectStr)
+		}
+		if len(aggrs[i].Triples) != exp.triples {
+			t.Errorf("Wrong number of triples in aggregate %d (Expected %d, got %d)", i, exp.triples, len(aggrs[i].Triples))
+		}
+	}
+
+	if len(errs) != 1 || errs[0].Input != "http://example.org/s1" {
+		t.Errorf("Expected one error for the subject appearing again, got: %v", errs)
+	}
+}
+
+// TestStreamingTripleAggregatorRecent tests that only subjects among the most
+// recent ones are remembered to detect unsorted input
+func TestStreamingTripleAggregatorRecent(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/s1> <http://example.org/p1> "o1" .
+<http://example.org/s2> <http://example.org/p1> "o2" .
+<http://example.org/s3> <http://example.org/p1> "o3" .
+<http://example.org/s1> <http://example.org/p1> "o4" .
+<http://example.org/s3> <http://example.org/p1> "o5" .
+`
+	triples, err := rdf.NewTripleDecoder(strings.NewReader(testData), rdf.NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal("Could not decode n-triples test data")
+	}
+
+	aggregator := NewStreamingTripleAggregator()
+	aggregator.recent = 2
+	go func() {
+		defer close(aggregator.In)
+		for _, tr := range triples {
+			aggregator.In <- tr
+		}
+	}()
+	go aggregator.Run()
+	go func() {
+		for range aggregator.Out {
+		}
+	}()
+
+	errs := []*ConversionError{}
+	for err := range aggregator.OutError {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0].Input != "http://example.org/s3" {
+		t.Errorf("Expected one error, for the recent subject s3, got: %v", errs)
+	}
+}
@@ -1,39 +1,85 @@
 package components
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/flowbase/flowbase"
 )
 
+// StringFileWriter is a process that writes the strings it receives on its In
+// port to a file. If the file name ends in a compression extension (".gz" or
+// ".xz"), the file is compressed accordingly. The file name StdioFileName
+// ("-") writes to standard output, uncompressed.
+//
+// If the file can not be written, the error is reported as a
+// *ConversionError on the OutError port, and the remaining input is drained
+// and discarded, so that upstream processes can finish.
 type StringFileWriter struct {
 	In       chan string
 	OutDone  chan interface{}
+	OutError chan *ConversionError
 	fileName string
+	stdout   io.Writer
 }
 
 func NewStringFileWriter(fileName string) *StringFileWriter {
 	return &StringFileWriter{
 		In:       make(chan string, BUFSIZE),
 		OutDone:  make(chan interface{}, BUFSIZE),
+		OutError: make(chan *ConversionError, BUFSIZE),
 		fileName: fileName,
+		stdout:   os.Stdout,
 	}
 }
 
 func (p *StringFileWriter) Run() {
 	defer close(p.OutDone)
+	defer close(p.OutError)
 
-	fh, err := os.Create(p.fileName)
-	if err != nil {
-		panic("Could not create output file: " + err.Error())
-	}
-	defer fh.Close()
-	for s := range p.In {
-		fh.WriteString(s)
+	if err := p.writeFile(); err != nil {
+		p.OutError <- NewConversionError(p.fileName, 0, "", fmt.Errorf("could not write output file: %s", err.Error()))
+		for range p.In {
+		}
 	}
 
 	flowbase.Debug.Printf("Sending done signal on chan %v now in StringFileWriter ...\n", p.OutDone)
 	p.OutDone <- &DoneSignal{}
 }
 
+func (p *StringFileWriter) writeFile() (err error) {
+	var fh io.Writer = p.stdout
+	if p.fileName != StdioFileName {
+		fileFh, createErr := os.Create(p.fileName)
+		if createErr != nil {
+			return createErr
+		}
+		// Data may only be found not to fit on disk when the file is
+		// closed, so this is an error as well
+		defer func() {
+			if closeErr := fileFh.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		fh = fileFh
+	}
+
+	bufFh := bufio.NewWriter(fh)
+	w, err := newCompressingWriter(bufFh, DetectCompression(p.fileName))
+	if err != nil {
+		return err
+	}
+	for s := range p.In {
+		if _, err := w.Write([]byte(s)); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return bufFh.Flush()
+}
+
 type DoneSignal struct{}
@@ -0,0 +1,263 @@
+package components
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	str "strings"
+
+	"github.com/knakk/rdf"
+	"github.com/rdfio/rdf2smw/mwtitle"
+)
+
+// Strategies for making the titles of different resources unique, when they
+// would otherwise get the same title
+const (
+	// TitleCollisionNone leaves colliding titles as they are, so that the
+	// pages overwrite each other on import
+	TitleCollisionNone = "none"
+	// TitleCollisionNamespace appends the namespace prefix, as in
+	// "Title (dc)", or, for namespaces without a prefix, the last part of the
+	// namespace, as in "Title (elements)", or, for resources in the same
+	// namespace, the local name, as in "Bob (bob2)"
+	TitleCollisionNamespace = "namespace"
+	// TitleCollisionClass appends the title of the most specific class, as in
+	// "Mercury (Planet)"
+	TitleCollisionClass = "class"
+	// TitleCollisionHash appends a hash of the URI, as in "Mercury (3f2a9c)"
+	TitleCollisionHash = "hash"
+)
+
+var titleCollisionStrategies = []string{TitleCollisionNone, TitleCollisionNamespace, TitleCollisionClass, TitleCollisionHash}
+
+// TitleCollision describes a title that several resources would get, and the
+// titles given to them instead.
+type TitleCollision struct {
+	// Title is the page title shared by the resources
+	Title string
+	// URIs holds the URIs of the resources, sorted
+	URIs []string
+	// Resolved holds the titles given to the resources, in the same order as
+	// URIs
+	Resolved []string
+}
+
+// titleRegistry holds the titles of resources whose titles collide with
+// those of other resources, keyed by titleKey.
+type titleRegistry struct {
+	titles     map[string]string
+	collisions []*TitleCollision
+}
+
+// titleKey returns the key of a resource in the title registry. The same URI
+// can be both a property and a page, with different titles.
+func titleKey(uri string, uriType int) string {
+	return strconv.Itoa(uriType) + " " + uri
+}
+
+type titleEntry struct {
+	uri     string
+	uriType int
+	blank   bool
+}
+
+// buildTitleRegistry gives the title of every URI in the resource index (as
+// subject, predicate or object), and of every blank node getting a page of
+// its own, without resolving collisions, and resolves the titles shared by
+// several of them according to the title collision strategy of the
+// configuration. Blank nodes with the same triples get the same skolemized
+// title, and are told apart by hashes of their labels.
+//
+// The titles given are checked against all titles in use, including those of
+// resources without collisions. When the strategy does not give a unique
+// title, a hash of the URI is used instead.
+func (p *TripleAggregateToWikiPageConverter) buildTitleRegistry(resourceIndex ResourceIndex) *titleRegistry {
+	registry := &titleRegistry{titles: make(map[string]string)}
+	if p.conf.TitleCollisionStrategy == TitleCollisionNone {
+		return registry
+	}
+
+	seen := make(map[string]bool)
+	byTitle := make(map[string][]titleEntry)
+	add := func(uri string, uriType int, aggr *TripleAggregate) {
+		key := titleKey(uri, uriType)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		pageTitle, _ := p.convertUriToWikiTitle(uri, uriType, aggr)
+		byTitle[pageTitle] = append(byTitle[pageTitle], titleEntry{uri, uriType, isBlankNode(uri, aggr)})
+	}
+	p.eachAggregate(resourceIndex, func(aggr *TripleAggregate) {
+		if aggr.Subject.Type() == rdf.TermIRI {
+			add(aggr.SubjectStr, p.determineType(aggr), aggr)
+		} else if !p.isInlined(aggr.SubjectStr, resourceIndex) && !isCollectionNode(aggr) {
+			add(aggr.SubjectStr, p.determineType(aggr), aggr)
+		}
+		for _, tr := range aggr.Triples {
+			add(tr.Pred.String(), URITypePredicate, p.getAggregate(resourceIndex, tr.Pred.String()))
+			if _, ok := iriValueType(tr.Obj.String()); tr.Obj.Type() == rdf.TermIRI && !ok {
+				objAggr := p.getAggregate(resourceIndex, tr.Obj.String())
+				add(tr.Obj.String(), p.determineType(objAggr), objAggr)
+			}
+		}
+	})
+
+	// Titles given to resolve collisions must not be in use already
+	used := make(map[string]bool)
+	for title := range byTitle {
+		used[title] = true
+	}
+
+	for _, title := range sortedTitles(byTitle) {
+		entries := byTitle[title]
+		if len(entries) < 2 {
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].uri < entries[j].uri
+		})
+		collision := &TitleCollision{Title: title}
+		factTitles := make([]string, len(entries))
+		suffixes := make([][]string, len(entries))
+		for i, entry := range entries {
+			_, factTitles[i] = p.convertUriToWikiTitle(entry.uri, entry.uriType, p.getAggregate(resourceIndex, entry.uri))
+			suffixes[i] = p.titleSuffixes(entry, resourceIndex)
+		}
+
+		// Try the suffixes given by the strategy in turn, keeping the titles
+		// that tell a resource apart from the others
+		resolved := make([]string, len(entries))
+		for round := 0; ; round++ {
+			tried := make([]string, len(entries))
+			cnt := make(map[string]int)
+			for i := range entries {
+				if resolved[i] == "" && round < len(suffixes[i]) {
+					tried[i] = p.cleanTitle(withSuffix(factTitles[i], suffixes[i][round]))
+					cnt[tried[i]]++
+				}
+			}
+			if len(cnt) == 0 {
+				break
+			}
+			for i, entry := range entries {
+				if tried[i] != "" && tried[i] != factTitles[i] && cnt[tried[i]] == 1 && !used[wikiPageTitle(tried[i], entry.uriType)] {
+					resolved[i] = tried[i]
+					used[wikiPageTitle(tried[i], entry.uriType)] = true
+				}
+			}
+		}
+
+		// Fall back to hashes when the strategy does not tell the resources
+		// apart
+		for i, entry := range entries {
+			for n := 1; resolved[i] == ""; n++ {
+				hashed := entry.uri
+				if n > 1 {
+					hashed += " " + strconv.Itoa(n)
+				}
+				if candidate := withSuffix(factTitles[i], uriHash(hashed)); !used[wikiPageTitle(candidate, entry.uriType)] {
+					resolved[i] = candidate
+					used[wikiPageTitle(candidate, entry.uriType)] = true
+				}
+			}
+			registry.titles[titleKey(entry.uri, entry.uriType)] = resolved[i]
+			collision.URIs = append(collision.URIs, entry.uri)
+		}
+		collision.Resolved = resolved
+		registry.collisions = append(registry.collisions, collision)
+	}
+	return registry
+}
+
+// titleSuffixes returns the texts to try in turn to tell the resource of
+// entry apart from others with the same title, according to the title
+// collision strategy. It may be empty, if the strategy gives nothing for it.
+func (p *TripleAggregateToWikiPageConverter) titleSuffixes(entry titleEntry, resourceIndex ResourceIndex) []string {
+	suffixes := []string{}
+	switch p.conf.TitleCollisionStrategy {
+	case TitleCollisionNamespace:
+		if prefix, _, ok := p.Prefixes.Abbreviate(entry.uri); ok {
+			suffixes = append(suffixes, prefix)
+		}
+		if !entry.blank {
+			name := localName(entry.uri)
+			if nsName := localName(str.TrimRight(str.TrimSuffix(entry.uri, name), "/#")); nsName != "" {
+				suffixes = append(suffixes, nsName)
+			}
+			if name != "" {
+				suffixes = append(suffixes, name)
+			}
+		}
+	case TitleCollisionClass:
+		if class := p.mostSpecificClass(entry.uri, resourceIndex); class != "" {
+			suffixes = append(suffixes, class)
+		}
+	case TitleCollisionHash:
+		suffixes = append(suffixes, uriHash(entry.uri))
+	}
+	return suffixes
+}
+
+// mostSpecificClass returns the title of the class of the resource uri with
+// the most super categories, or "" if it has no class.
+func (p *TripleAggregateToWikiPageConverter) mostSpecificClass(uri string, resourceIndex ResourceIndex) string {
+	aggr := p.getAggregate(resourceIndex, uri)
+	if aggr == nil {
+		return ""
+	}
+	class, topSuperCatsCnt := "", -1
+	for _, tr := range aggr.Triples {
+		if tr.Pred.String() != typePropertyURI || tr.Obj.Type() != rdf.TermIRI {
+			continue
+		}
+		if superCatsCnt := p.countSuperCategories(tr, resourceIndex); superCatsCnt > topSuperCatsCnt {
+			classAggr := p.getAggregate(resourceIndex, tr.Obj.String())
+			_, class = p.convertUriToWikiTitle(tr.Obj.String(), p.determineType(classAggr), classAggr)
+			topSuperCatsCnt = superCatsCnt
+		}
+	}
+	return class
+}
+
+// withSuffix appends suffix to title in parentheses, shortening title if
+// needed to stay within the maximum title length.
+func withSuffix(title string, suffix string) string {
+	suffix = " (" + suffix + ")"
+	return mwtitle.Truncate(title, mwtitle.MaxTitleBytes-len(suffix)) + suffix
+}
+
+// uriHash returns a short hash of uri, for telling titles apart.
+func uriHash(uri string) string {
+	hash := sha1.Sum([]byte(uri))
+	return hex.EncodeToString(hash[:])[:6]
+}
+
+func sortedTitles(byTitle map[string][]titleEntry) []string {
+	titles := []string{}
+	for title := range byTitle {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// WriteTitleCollisionReport writes the title collisions as tab-separated
+// lines of the shared title, the URI of a resource, and the title given to
+// it, with a header line.
+func WriteTitleCollisionReport(w io.Writer, collisions []*TitleCollision) error {
+	if _, err := fmt.Fprintln(w, "title\turi\tresolved title"); err != nil {
+		return err
+	}
+	for _, collision := range collisions {
+		for i, uri := range collision.URIs {
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", collision.Title, uri, collision.Resolved[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package components
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	str "strings"
+)
+
+// trigReader converts TriG to Turtle as it is read from r, by removing the
+// graph blocks around triples: the braces, and the graph names (optionally
+// preceded by the GRAPH keyword) before them. The last triple of a block may
+// lack its final '.' in TriG, so one is added where needed. Graph names have
+// no correspondence in the wiki, and are dropped, as for N-Quads. Comments
+// are dropped as well, so that they are not taken for graph names.
+//
+// Since a graph name can only be told from the end of a directive or triple
+// once the '{' after it is read, the last few words at the top level are held
+// back until then.
+type trigReader struct {
+	r          *bufio.Reader
+	out        []byte
+	held       []byte
+	wordStarts []int
+	inWord     bool
+	inBlock    bool
+	lastSig    byte
+	err        error
+}
+
+// trigHeldWords is the number of words held back at the top level, enough
+// for "PREFIX ex: <iri>", which ends with what could be a graph name.
+const trigHeldWords = 3
+
+func newTriGReader(r io.Reader) *trigReader {
+	return &trigReader{r: bufio.NewReader(r)}
+}
+
+func (t *trigReader) Read(buf []byte) (int, error) {
+	for len(t.out) == 0 && t.err == nil {
+		t.convert(4096)
+	}
+	n := copy(buf, t.out)
+	t.out = t.out[n:]
+	if len(t.out) == 0 && t.err != nil {
+		return n, t.err
+	}
+	return n, nil
+}
+
+// convert converts up to n bytes of input, or to the end of it.
+func (t *trigReader) convert(n int) {
+	for i := 0; i < n; i++ {
+		c, err := t.r.ReadByte()
+		if err != nil {
+			t.flush(len(t.held))
+			t.err = err
+			return
+		}
+		switch {
+		case c == '#':
+			t.skipComment()
+			t.inWord = false
+			t.emit('\n')
+		case c == '<':
+			t.startWord()
+			t.emit(c)
+			t.copyUntil('>')
+		case c == '"' || c == '\'':
+			t.startWord()
+			t.emit(c)
+			t.copyString(c)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			t.inWord = false
+			t.emit(c)
+		case c == '{' && !t.inBlock:
+			t.dropGraphName()
+			t.flush(len(t.held))
+			t.inBlock, t.inWord, t.lastSig = true, false, c
+			t.emit(' ')
+		case c == '}' && t.inBlock:
+			if t.lastSig != '.' && t.lastSig != '{' {
+				t.emit(' ')
+				t.emit('.')
+			}
+			t.inBlock, t.inWord = false, false
+			t.emit('\n')
+		default:
+			t.startWord()
+			t.emit(c)
+		}
+	}
+}
+
+// emit writes c to the output, holding it back if at the top level.
+func (t *trigReader) emit(c byte) {
+	if t.inBlock {
+		t.out = append(t.out, c)
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			t.lastSig = c
+		}
+		return
+	}
+	t.held = append(t.held, c)
+}
+
+// startWord marks the start of a word at the top level, if not in one, and
+// releases the held back text before the last words.
+func (t *trigReader) startWord() {
+	if t.inBlock || t.inWord {
+		return
+	}
+	t.inWord = true
+	t.wordStarts = append(t.wordStarts, len(t.held))
+	if len(t.wordStarts) > trigHeldWords {
+		t.flush(t.wordStarts[len(t.wordStarts)-trigHeldWords])
+	}
+}
+
+// flush releases the first n bytes of held back text to the output.
+func (t *trigReader) flush(n int) {
+	t.out = append(t.out, t.held[:n]...)
+	t.held = append(t.held[:0], t.held[n:]...)
+	starts := t.wordStarts[:0]
+	for _, start := range t.wordStarts {
+		if start >= n {
+			starts = append(starts, start-n)
+		}
+	}
+	t.wordStarts = starts
+}
+
+// dropGraphName removes the graph name, and the GRAPH keyword if any, from
+// the end of the held back text, unless the last word ends a directive.
+func (t *trigReader) dropGraphName() {
+	words := []string{}
+	for i, start := range t.wordStarts {
+		end := len(t.held)
+		if i+1 < len(t.wordStarts) {
+			end = t.wordStarts[i+1]
+		}
+		words = append(words, string(bytes.TrimSpace(t.held[start:end])))
+	}
+	n := len(words)
+	if n == 0 {
+		return
+	}
+	first := n - 1
+	if words[n-1] == "]" && n >= 2 && words[n-2] == "[" {
+		first = n - 2
+	} else if !isTriGGraphName(words[n-1]) ||
+		(n >= 2 && str.EqualFold(words[n-2], "BASE")) ||
+		(n >= 3 && str.EqualFold(words[n-3], "PREFIX")) {
+		return
+	}
+	if first > 0 && str.EqualFold(words[first-1], "GRAPH") {
+		first--
+	}
+	t.held = t.held[:t.wordStarts[first]]
+	t.wordStarts = t.wordStarts[:first]
+}
+
+// isTriGGraphName tells whether word can be a graph name: an IRI, a prefixed
+// name or a blank node.
+func isTriGGraphName(word string) bool {
+	if str.HasSuffix(word, ".") {
+		return false
+	}
+	return word == "[]" || str.HasPrefix(word, "<") || str.HasPrefix(word, "_:") || str.Contains(word, ":")
+}
+
+// skipComment skips the rest of a comment line, including the line break.
+func (t *trigReader) skipComment() {
+	for {
+		c, err := t.r.ReadByte()
+		if err != nil || c == '\n' {
+			return
+		}
+	}
+}
+
+// copyUntil copies the input up to and including the byte end.
+func (t *trigReader) copyUntil(end byte) {
+	for {
+		c, err := t.r.ReadByte()
+		if err != nil {
+			return
+		}
+		t.emit(c)
+		if c == end {
+			return
+		}
+	}
+}
+
+// copyString copies the rest of a string started by the quote character
+// quote, which may be a long string (three quotes).
+func (t *trigReader) copyString(quote byte) {
+	long := false
+	if next, err := t.r.Peek(2); err == nil && next[0] == quote && next[1] == quote {
+		t.r.Discard(2)
+		t.emit(quote)
+		t.emit(quote)
+		long = true
+	}
+	quotes := 0
+	for {
+		c, err := t.r.ReadByte()
+		if err != nil {
+			return
+		}
+		t.emit(c)
+		switch {
+		case c == '\\':
+			if escaped, err := t.r.ReadByte(); err == nil {
+				t.emit(escaped)
+			}
+			quotes = 0
+		case c == quote:
+			quotes++
+			if !long || quotes == 3 {
+				return
+			}
+		default:
+			quotes = 0
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package components
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/knakk/rdf"
+)
+
+// TestTriGReader tests that TriG is read as the triples of all its graphs,
+// with named, GRAPH and default graph blocks, and with braces, '#' and quotes
+// in IRIs and strings left alone
+func TestTriGReader(t *testing.T) {
+	trig := `@prefix ex: <http://example.org/> .
+PREFIX dc: <http://purl.org/dc/terms/>
+# A comment with ex:graph and { braces }
+ex:s1 ex:p "top level" .
+ex:g1 { ex:s2 ex:p "in a named graph" . }
+GRAPH <http://example.org/g2> {
+  ex:s3 ex:p "no final dot", """long { "string" } # """
+}
+{ ex:s4 dc:title 'default graph {}' }
+_:g3 { ex:s5 ex:p <http://example.org/x#y> ; ex:q 1.5 }
+[] { ex:s6 ex:p "blank graph" }
+`
+	dec := newTripleDecoder(newTriGReader(strings.NewReader(trig)), rdf.Turtle)
+	objects := []string{}
+	for triple, err := dec.Decode(); err != io.EOF; triple, err = dec.Decode() {
+		if err != nil {
+			t.Fatalf("Could not decode converted TriG: %s", err.Error())
+		}
+		objects = append(objects, triple.Subj.String()+" "+triple.Obj.String())
+	}
+	expected := []string{
+		"http://example.org/s1 top level",
+		"http://example.org/s2 in a named graph",
+		"http://example.org/s3 no final dot",
+		"http://example.org/s3 long { \"string\" } # ",
+		"http://example.org/s4 default graph {}",
+		"http://example.org/s5 http://example.org/x#y",
+		"http://example.org/s5 1.5",
+		"http://example.org/s6 blank graph",
+	}
+	if strings.Join(objects, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong triples read from TriG:\n%s\nExpected:\n%s", strings.Join(objects, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -1,37 +1,46 @@
 package components
 
 import (
+	"fmt"
 	"io"
-	"log"
 	str "strings"
 
 	"github.com/knakk/rdf"
 )
 
+// TripleParser is a process that parses the lines of turtle it receives on its
+// In port into triples, sent on its Out port. Lines that can not be parsed are
+// skipped, and reported as *ConversionError's on the OutError port.
 type TripleParser struct {
-	In  chan string
-	Out chan rdf.Triple
+	In       chan string
+	Out      chan rdf.Triple
+	OutError chan *ConversionError
 }
 
 func NewTripleParser() *TripleParser {
 	return &TripleParser{
-		In:  make(chan string, BUFSIZE),
-		Out: make(chan rdf.Triple, BUFSIZE),
+		In:       make(chan string, BUFSIZE),
+		Out:      make(chan rdf.Triple, BUFSIZE),
+		OutError: make(chan *ConversionError, BUFSIZE),
 	}
 }
 
 func (p *TripleParser) Run() {
 	defer close(p.Out)
+	defer close(p.OutError)
+	lineNo := 0
 	for line := range p.In {
+		lineNo++
 		lineReader := str.NewReader(line)
 		dec := rdf.NewTripleDecoder(lineReader, rdf.Turtle)
 		for triple, err := dec.Decode(); err != io.EOF; triple, err = dec.Decode() {
 			if err != nil {
-				log.Fatal("Could not encode to triple: ", err.Error())
+				p.OutError <- NewConversionError("", lineNo, line, fmt.Errorf("could not decode triple: %s", err.Error()))
+				break
 			} else if triple.Subj != nil && triple.Pred != nil && triple.Obj != nil {
 				p.Out <- triple
 			} else {
-				log.Fatal("Something was encoded as nil in the triple:", triple)
+				p.OutError <- NewConversionError("", lineNo, line, fmt.Errorf("triple with missing subject, predicate or object"))
 			}
 		}
 	}
@@ -1,47 +1,26 @@
 package components
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
 	"regexp"
+	"sort"
 	str "strings"
+	"sync"
 
 	"github.com/knakk/rdf"
+	"github.com/rdfio/rdf2smw/mwtitle"
 )
 
 // Constants etc ---------------------------------------------------------------
 
-var titleProperties = []string{
-	"http://semantic-mediawiki.org/swivt/1.0#page",
-	"http://www.w3.org/2000/01/rdf-schema#label",
-	"http://purl.org/dc/elements/1.1/title",
-	"http://purl.org/dc/terms/title",
-	"http://www.w3.org/2004/02/skos/core#preferredLabel",
-	"http://xmlns.com/foaf/0.1/name",
-}
-
-var namespaceAbbreviations = map[string]string{
-	"http://www.opentox.org/api/1.1#": "opentox",
-}
-
-var propertyTypes = []string{
-	"http://www.w3.org/2002/07/owl#AnnotationProperty",
-	"http://www.w3.org/2002/07/owl#DatatypeProperty",
-	"http://www.w3.org/2002/07/owl#ObjectProperty",
-}
-
-var categoryTypes = []string{
-	"http://www.w3.org/2002/07/owl#Class",
-}
-
 const (
 	typePropertyURI     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
 	subClassPropertyURI = "http://www.w3.org/2000/01/rdf-schema#subClassOf"
-)
-
-const (
-	dataTypeURIString     = "http://www.w3.org/2001/XMLSchema#string"
-	dataTypeURILangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
-	dataTypeURIInteger    = "http://www.w3.org/2001/XMLSchema#integer"
-	dataTypeURIFloat      = "http://www.w3.org/2001/XMLSchema#float"
+	rangePropertyURI    = "http://www.w3.org/2000/01/rdf-schema#range"
+	domainPropertyURI   = "http://www.w3.org/2000/01/rdf-schema#domain"
+	oneOfPropertyURI    = "http://www.w3.org/2002/07/owl#oneOf"
 )
 
 const (
@@ -56,18 +35,61 @@ const (
 
 // TripleAggregateToWikiPageConverter takes *TripleAggregate's and converts
 // them into a *WikiPage which can be used to generate wiki text content.
+//
+// The conversion is done by Workers go-routines in parallel. Property pages
+// are gathered from all aggregates, and sent at the end. With more than one
+// worker, pages are sent in the order they are done, unless KeepOrder is set,
+// in which case they are sent in the same order as with one worker.
+//
+// The SMW type ("Has type") of each property is decided at the end, from the
+// types of all its values, or from its rdfs:range if given. Properties with
+// values of several types are resolved according to the type conflict policy
+// of the configuration, and can be listed with TypeConflicts.
+//
+// Titles are made from namespace prefixes and local names of URIs according
+// to the abbreviation policy of the configuration, with the prefixes in
+// Prefixes, which may be shared with the file readers to get the prefixes
+// declared in the input.
+//
+// Different resources that would get the same title are given unique titles
+// according to the title collision strategy of the configuration, and can be
+// listed with TitleCollisions.
+//
+// Triples that can not be read back from the resource index are reported as
+// *ConversionError's on the OutError port, once per subject, and the rest of
+// the triples of the subject converted.
 type TripleAggregateToWikiPageConverter struct {
-	InAggregate    chan *TripleAggregate
-	InIndex        chan *map[string]*TripleAggregate
-	OutPage        chan *WikiPage
-	cleanUpRegexes []*regexp.Regexp
+	InAggregate     chan *TripleAggregate
+	InIndex         chan ResourceIndex
+	OutPage         chan *WikiPage
+	OutError        chan *ConversionError
+	Workers         int
+	KeepOrder       bool
+	Prefixes        *NamespacePrefixes
+	cleanUpRegexes  []*regexp.Regexp
+	titleNormalizer *mwtitle.Normalizer
+	collidingNames  map[string]bool
+	blankCycles     map[string]bool
+	titles          *titleRegistry
+	conf            *Config
+	typeConflicts   []*TypeConflict
+	indexErrors     map[string]bool
+	indexErrorsMu   sync.Mutex
 }
 
-func NewTripleAggregateToWikiPageConverter() *TripleAggregateToWikiPageConverter {
+// NewTripleAggregateToWikiPageConverter returns an initialized
+// TripleAggregateToWikiPageConverter, using the mapping configuration conf.
+func NewTripleAggregateToWikiPageConverter(conf *Config) *TripleAggregateToWikiPageConverter {
 	return &TripleAggregateToWikiPageConverter{
-		InAggregate: make(chan *TripleAggregate, BUFSIZE),
-		InIndex:     make(chan *map[string]*TripleAggregate, BUFSIZE),
-		OutPage:     make(chan *WikiPage, BUFSIZE),
+		InAggregate:     make(chan *TripleAggregate, BUFSIZE),
+		InIndex:         make(chan ResourceIndex, BUFSIZE),
+		OutPage:         make(chan *WikiPage, BUFSIZE),
+		OutError:        make(chan *ConversionError, BUFSIZE),
+		Workers:         1,
+		Prefixes:        NewNamespacePrefixes(conf.NamespaceAbbreviations),
+		titleNormalizer: mwtitle.NewNormalizer(conf.CapitalLinks, conf.ReservedTitlePrefixes),
+		conf:            conf,
+		indexErrors:     make(map[string]bool),
 		cleanUpRegexes: []*regexp.Regexp{
 			regexp.MustCompile(" [(][^)]*:[^)]*[)]"),
 			regexp.MustCompile(" [[][^]]*:[^]]*[]]"),
@@ -75,115 +97,574 @@ func NewTripleAggregateToWikiPageConverter() *TripleAggregateToWikiPageConverter
 	}
 }
 
+// keepOrderWindow is the number of jobs per worker that are handed out ahead
+// of the next result in order, when keeping the order.
+const keepOrderWindow = 4
+
+// conversionJob is an aggregate to convert, numbered in input order.
+type conversionJob struct {
+	seq  int
+	aggr *TripleAggregate
+}
+
+// conversionResult is the result of converting one aggregate: the page, if
+// it is to be sent right away, the (partial) property pages to merge into the
+// property page index, and the types of values seen per property.
+type conversionResult struct {
+	seq       int
+	page      *WikiPage
+	predPages []*WikiPage
+	types     typeTally
+	rangeType string
+}
+
 func (p *TripleAggregateToWikiPageConverter) Run() {
 	defer close(p.OutPage)
-
-	predPageIndex := make(map[string]*WikiPage)
+	defer close(p.OutError)
 
 	resourceIndex := <-p.InIndex
 
-	for aggr := range p.InAggregate {
-		pageType := p.determineType(aggr)
+	if p.conf.AbbreviationPolicy == AbbreviateOnCollision {
+		p.collidingNames = p.findCollidingLocalNames(resourceIndex)
+	}
+	p.blankCycles = p.findBlankNodeCycles(resourceIndex)
+	p.titles = p.buildTitleRegistry(resourceIndex)
 
-		pageTitle, _ := p.convertUriToWikiTitle(aggr.SubjectStr, pageType, resourceIndex)
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-		page := NewWikiPage(pageTitle, []*Fact{}, []*Category{}, nil, pageType)
+	// When keeping the order, results done ahead of the next one in order
+	// wait until it is done. To keep memory use bounded when a conversion
+	// is slow, at most keepOrderWindow jobs per worker are handed out ahead
+	// of the results sent so far. Each job takes a slot in window, which is
+	// given back when its result is sent.
+	var window chan struct{}
+	if p.KeepOrder {
+		window = make(chan struct{}, keepOrderWindow*workers)
+	}
 
-		topSuperCatsCnt := 0
-		for _, tr := range aggr.Triples {
+	jobs := make(chan conversionJob, BUFSIZE)
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for aggr := range p.InAggregate {
+			if window != nil {
+				window <- struct{}{}
+			}
+			jobs <- conversionJob{seq, aggr}
+			seq++
+		}
+	}()
+
+	results := make(chan conversionResult, BUFSIZE)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results <- p.convertAggregate(job.seq, job.aggr, resourceIndex)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-			predTitle, propertyStr := p.convertUriToWikiTitle(tr.Pred.String(), URITypePredicate, resourceIndex) // Here we know it is a predicate, simply because its location in a triple
+	// The property page index is only accessed from this go-routine, where
+	// the results of all workers are merged
+	predPageIndex := make(map[string]*WikiPage)
+	predPageTitles := []string{}
+	types := make(typeTally)
+	rangeTypes := make(map[string]string)
+	handleResult := func(res conversionResult) {
+		types.merge(res.types)
+		if res.rangeType != "" {
+			rangeTypes[res.predPages[len(res.predPages)-1].Title] = res.rangeType
+		}
+		for _, predPage := range res.predPages {
+			if existing, ok := predPageIndex[predPage.Title]; ok {
+				for _, fact := range predPage.Facts {
+					existing.AddFactUnique(fact)
+				}
+				for _, cat := range predPage.Categories {
+					existing.AddCategoryUnique(cat)
+				}
+				for _, domain := range predPage.Domains {
+					existing.AddDomainUnique(domain)
+				}
+				for _, redirect := range predPage.Redirects {
+					existing.AddRedirectUnique(redirect)
+				}
+				existing.Subobjects = append(existing.Subobjects, predPage.Subobjects...)
+			} else {
+				predPageIndex[predPage.Title] = predPage
+				predPageTitles = append(predPageTitles, predPage.Title)
+			}
+		}
+		if res.page != nil {
+			p.OutPage <- res.page
+		}
+	}
 
-			// Make sure property page exists
-			if predPageIndex[predTitle] == nil {
-				predPageIndex[predTitle] = NewWikiPage(predTitle, []*Fact{}, []*Category{}, nil, URITypePredicate)
+	if p.KeepOrder {
+		pending := make(map[int]conversionResult)
+		nextSeq := 0
+		for res := range results {
+			pending[res.seq] = res
+			for res, ok := pending[nextSeq]; ok; res, ok = pending[nextSeq] {
+				delete(pending, nextSeq)
+				handleResult(res)
+				<-window
+				nextSeq++
 			}
+		}
+	} else {
+		for res := range results {
+			handleResult(res)
+		}
+	}
 
-			var valueStr string
+	for _, predTitle := range predPageTitles {
+		smwType, conflict := chooseType(predTitle, types[predTitle], rangeTypes[predTitle], p.conf.TypeConflictPolicy)
+		if smwType != "" {
+			predPageIndex[predTitle].AddFactUnique(NewFact("Has type", smwType))
+		}
+		if conflict != nil {
+			p.typeConflicts = append(p.typeConflicts, conflict)
+		}
+		p.OutPage <- predPageIndex[predTitle]
+	}
+}
 
-			if tr.Obj.Type() == rdf.TermIRI {
+// TypeConflicts returns the properties that had values of several types, or
+// values not matching their rdfs:range. It should only be called after the
+// process has finished.
+func (p *TripleAggregateToWikiPageConverter) TypeConflicts() []*TypeConflict {
+	return p.typeConflicts
+}
 
-				valueAggr := (*resourceIndex)[tr.Obj.String()]
-				valueUriType := p.determineType(valueAggr)
-				_, valueStr = p.convertUriToWikiTitle(tr.Obj.String(), valueUriType, resourceIndex)
+// TitleCollisions returns the titles shared by several resources, and the
+// titles given to them instead. It is only complete after Run has returned.
+func (p *TripleAggregateToWikiPageConverter) TitleCollisions() []*TitleCollision {
+	if p.titles == nil {
+		return nil
+	}
+	return p.titles.collisions
+}
+
+// convertAggregate converts the triples of one subject into a wiki page. The
+// result holds the page, or nil if it is a property page, together with the
+// property pages to create or add facts to, in the order they are to be
+// merged into the property page index, with the property page itself last.
+//
+// Blank nodes that are referenced only once are not converted into pages of
+// their own, but inlined as subobjects of the page referencing them, and the
+// nodes of collections and containers become values of the facts referencing
+// them, so no result page is returned for them.
+func (p *TripleAggregateToWikiPageConverter) convertAggregate(seq int, aggr *TripleAggregate, resourceIndex ResourceIndex) conversionResult {
+	if _, ok := aggr.Subject.(rdf.Blank); ok && p.getAggregate(resourceIndex, aggr.SubjectStr) != nil {
+		if p.isInlined(aggr.SubjectStr, resourceIndex) || isCollectionNode(aggr) {
+			return conversionResult{seq: seq}
+		}
+	}
+
+	pageType := p.determineType(aggr)
 
-				predPageIndex[predTitle].AddFactUnique(NewFact("Has type", "Page"))
+	titleAggr := aggr
+	if _, ok := aggr.Subject.(rdf.Blank); ok && p.getAggregate(resourceIndex, aggr.SubjectStr) == nil {
+		// Without the triples referring to a blank node in the index, the
+		// pages referring to it can only know its label
+		titleAggr = NewTripleAggregate(aggr.Subject, nil)
+	}
+	pageTitle, _ := p.convertUriToWikiTitle(aggr.SubjectStr, pageType, titleAggr)
 
-			} else if tr.Obj.Type() == rdf.TermLiteral {
+	page := NewWikiPage(pageTitle, []*Fact{}, []*Category{}, nil, pageType)
 
-				valueStr = tr.Obj.String()
+	conv := &pageConversion{
+		page:          page,
+		predPages:     []*WikiPage{},
+		predPageIndex: make(map[string]*WikiPage),
+		types:         make(typeTally),
+		visited:       map[string]bool{aggr.SubjectStr: true},
+	}
 
-				for _, r := range p.cleanUpRegexes {
-					valueStr = r.ReplaceAllString(valueStr, "")
+	if pageType == URITypeClass && p.conf.CategoryPages.Enabled {
+		p.buildCategoryPage(conv, aggr, resourceIndex)
+	} else {
+		topSuperCatsCnt := 0
+		for _, tr := range aggr.Triples {
+			for _, fact := range p.convertTriple(tr, conv, resourceIndex) {
+				if tr.Pred.String() == typePropertyURI || tr.Pred.String() == subClassPropertyURI {
+					page.AddCategoryUnique(NewCategory(fact.Value))
+					superCatsCnt := p.countSuperCategories(tr, resourceIndex)
+					if superCatsCnt > topSuperCatsCnt {
+						topSuperCatsCnt = superCatsCnt
+						page.SpecificCategory = NewCategory(fact.Value)
+						//println("Page:", page.Title, " | Adding cat", fact.Value, "since has", superCatsCnt, "super categories.")
+					}
+				} else {
+					page.AddFactUnique(fact)
 				}
+			}
+		}
+	}
+
+	if p.conf.Redirects.Enabled && (pageType == URITypeUndefined || pageType == URITypePredicate) {
+		p.addRedirects(page, aggr, pageType, resourceIndex)
+	}
+
+	// Add Equivalent URI fact (blank nodes have no URI to refer to)
+	if _, ok := aggr.Subject.(rdf.Blank); !ok {
+		equivURIFact := NewFact("Equivalent URI", aggr.Subject.String())
+		page.AddFactUnique(equivURIFact)
+	}
+
+	// Don't send predicates just yet (we want to gather facts about them,
+	// and send at the end) ...
+	if pageType == URITypePredicate {
+		rangeType := p.applySchema(aggr, page, resourceIndex)
+		return conversionResult{seq, nil, append(conv.predPages, page), conv.types, rangeType}
+	}
+	return conversionResult{seq, page, conv.predPages, conv.types, ""}
+}
 
-				dataTypeStr := tr.Obj.(rdf.Literal).DataType.String()
-
-				// Add type info on the current property's page
-				switch dataTypeStr {
-				case dataTypeURIString:
-					predPageIndex[predTitle].AddFactUnique(NewFact("Has type", "Text"))
-				case dataTypeURILangString:
-					predPageIndex[predTitle].AddFactUnique(NewFact("Has type", "Text"))
-				case dataTypeURIInteger:
-					predPageIndex[predTitle].AddFactUnique(NewFact("Has type", "Number"))
-				case dataTypeURIFloat:
-					predPageIndex[predTitle].AddFactUnique(NewFact("Has type", "Number"))
+// pageConversion holds the state of the conversion of one aggregate into a
+// page: the property pages seen, the types of their values, and the blank
+// nodes inlined into the page so far.
+type pageConversion struct {
+	page          *WikiPage
+	predPages     []*WikiPage
+	predPageIndex map[string]*WikiPage
+	types         typeTally
+	visited       map[string]bool
+}
+
+// convertTriple converts the predicate and object of a triple into facts,
+// making sure the property page exists, and counting the types of the values.
+// Blank node objects that are referenced only by this triple are added as
+// subobjects to the page, with the subobject as value. Collections (rdf:first
+// / rdf:rest lists) and containers (rdf:Seq, rdf:Bag and rdf:Alt) give one
+// fact per member, numbered in order.
+func (p *TripleAggregateToWikiPageConverter) convertTriple(tr rdf.Triple, conv *pageConversion, resourceIndex ResourceIndex) []*Fact {
+	predTitle, propertyStr := p.convertUriToWikiTitle(tr.Pred.String(), URITypePredicate, p.getAggregate(resourceIndex, tr.Pred.String())) // Here we know it is a predicate, simply because its location in a triple
+
+	lang := literalLang(tr.Obj)
+	if lang != "" && p.conf.LanguageMode == LanguageModeProperties {
+		predTitle = languageProperty(predTitle, lang)
+		propertyStr = languageProperty(propertyStr, lang)
+	}
+
+	// Make sure property page exists
+	if conv.predPageIndex[predTitle] == nil {
+		conv.predPageIndex[predTitle] = NewWikiPage(predTitle, []*Fact{}, []*Category{}, nil, URITypePredicate)
+		conv.predPages = append(conv.predPages, conv.predPageIndex[predTitle])
+	}
+
+	if members, ok := p.collectionMembers(tr.Obj, resourceIndex); ok {
+		facts := []*Fact{}
+		for i, member := range members {
+			fact := NewFact(propertyStr, "")
+			fact.Value, fact.DataType = p.convertObject(member, predTitle, propertyStr, conv, resourceIndex)
+			fact.ListIndex = i + 1
+			facts = append(facts, fact)
+		}
+		return facts
+	}
+	fact := NewFact(propertyStr, "")
+	fact.Value, fact.DataType = p.convertObject(tr.Obj, predTitle, propertyStr, conv, resourceIndex)
+	return []*Fact{fact}
+}
+
+// convertObject converts the object of a triple into a value of the property
+// predTitle, and returns it with its SMW type (or smwTypeText for literals of
+// datatypes without an SMW type).
+func (p *TripleAggregateToWikiPageConverter) convertObject(obj rdf.Object, predTitle string, propertyStr string, conv *pageConversion, resourceIndex ResourceIndex) (valueStr string, valueType string) {
+	if smwType, ok := iriValueType(obj.String()); ok && obj.Type() == rdf.TermIRI {
+
+		// E-mail addresses and phone numbers are values, not pages
+		valueStr = normalizeValue(smwType, obj.String())
+		conv.types.add(predTitle, smwType)
+		valueType = smwType
+
+	} else if obj.Type() == rdf.TermBlank {
+
+		blankLabel := obj.String()
+		if p.isInlined(blankLabel, resourceIndex) && !conv.visited[blankLabel] {
+			conv.visited[blankLabel] = true
+			subobject := NewSubobject(fmt.Sprintf("%s %d", propertyStr, len(conv.page.Subobjects)+1))
+			conv.page.Subobjects = append(conv.page.Subobjects, subobject)
+			for _, subTr := range p.getAggregate(resourceIndex, blankLabel).Triples {
+				for _, fact := range p.convertTriple(subTr, conv, resourceIndex) {
+					subobject.AddFactUnique(fact)
 				}
 			}
+			valueStr = conv.page.Title + "#" + subobject.Name
+		} else {
+			valueAggr := p.getAggregate(resourceIndex, blankLabel)
+			if valueAggr == nil {
+				valueAggr = NewTripleAggregate(obj.(rdf.Blank), nil)
+			}
+			_, valueStr = p.convertUriToWikiTitle(blankLabel, p.determineType(valueAggr), valueAggr)
+		}
+		conv.types.add(predTitle, smwTypePage)
+		valueType = smwTypePage
 
-			if tr.Pred.String() == typePropertyURI || tr.Pred.String() == subClassPropertyURI {
-				page.AddCategoryUnique(NewCategory(valueStr))
-				superCatsCnt := p.countSuperCategories(tr, resourceIndex)
-				if superCatsCnt > topSuperCatsCnt {
-					topSuperCatsCnt = superCatsCnt
-					page.SpecificCategory = NewCategory(valueStr)
-					//println("Page:", page.Title, " | Adding cat", valueStr, "since has", superCatsCnt, "super categories.")
+	} else if obj.Type() == rdf.TermIRI {
+
+		valueAggr := p.getAggregate(resourceIndex, obj.String())
+		valueUriType := p.determineType(valueAggr)
+		_, valueStr = p.convertUriToWikiTitle(obj.String(), valueUriType, valueAggr)
+
+		conv.types.add(predTitle, smwTypePage)
+		valueType = smwTypePage
+
+	} else if obj.Type() == rdf.TermLiteral {
+
+		valueStr = obj.String()
+
+		for _, r := range p.cleanUpRegexes {
+			valueStr = r.ReplaceAllString(valueStr, "")
+		}
+
+		dataTypeStr := obj.(rdf.Literal).DataType.String()
+		lang := literalLang(obj)
+
+		// Add type info on the current property's page, and convert the
+		// value to a format SMW accepts for the type
+		smwType, ok := p.conf.DataTypes[dataTypeStr]
+		if lang != "" && p.conf.LanguageMode == LanguageModeMonolingual {
+			smwType, ok = smwTypeMonolingual, true
+		}
+		valueType = smwTypeText
+		if ok {
+			conv.types.add(predTitle, smwType)
+			valueStr = normalizeValue(smwType, valueStr)
+			valueType = smwType
+		}
+		if smwType == smwTypeMonolingual {
+			valueStr += "@" + lang
+		}
+	}
+	return valueStr, valueType
+}
+
+// getAggregate returns the aggregate of the subject subjectStr from
+// resourceIndex, like ResourceIndex.Get, reporting any error of reading it.
+func (p *TripleAggregateToWikiPageConverter) getAggregate(resourceIndex ResourceIndex, subjectStr string) *TripleAggregate {
+	aggr, err := resourceIndex.Get(subjectStr)
+	if err != nil {
+		p.reportIndexError(err)
+	}
+	return aggr
+}
+
+// eachAggregate calls fn for every aggregate in resourceIndex, like
+// ResourceIndex.Each, reporting any errors of reading them.
+func (p *TripleAggregateToWikiPageConverter) eachAggregate(resourceIndex ResourceIndex, fn func(aggr *TripleAggregate)) {
+	resourceIndex.Each(func(aggr *TripleAggregate, err error) {
+		if err != nil {
+			p.reportIndexError(err)
+		}
+		if aggr != nil {
+			fn(aggr)
+		}
+	})
+}
+
+// reportIndexError sends err on the OutError port, unless it has been sent
+// already, as the same subjects are read from the index many times.
+func (p *TripleAggregateToWikiPageConverter) reportIndexError(err error) {
+	p.indexErrorsMu.Lock()
+	defer p.indexErrorsMu.Unlock()
+	if p.indexErrors[err.Error()] {
+		return
+	}
+	p.indexErrors[err.Error()] = true
+	p.OutError <- NewConversionError("", 0, "", err)
+}
+
+// isInlined tells whether the blank node blankLabel is to be inlined as a
+// subobject of the one page referencing it, rather than getting a page of its
+// own: it must be referenced exactly once, have triples of its own, and be
+// reachable from a page, rather than only from blank nodes referring to each
+// other in a cycle.
+func (p *TripleAggregateToWikiPageConverter) isInlined(blankLabel string, resourceIndex ResourceIndex) bool {
+	if resourceIndex.References(blankLabel) != 1 || p.blankCycles[blankLabel] {
+		return false
+	}
+	return p.getAggregate(resourceIndex, blankLabel) != nil
+}
+
+// findBlankNodeCycles returns the blank nodes that are referenced exactly
+// once, but only from each other in a cycle (such as a blank node referring
+// to itself), so that they can not be reached from any page. Inlining these
+// would drop them, as no page would hold them. Blank nodes referenced from a
+// cycle are not included, as they can be inlined in the pages of the cycle.
+func (p *TripleAggregateToWikiPageConverter) findBlankNodeCycles(resourceIndex ResourceIndex) map[string]bool {
+	// The blank node referring to each blank node referenced once, or "" if
+	// it is referred to by an IRI, or by a blank node not referenced once
+	referrers := make(map[string]string)
+	p.eachAggregate(resourceIndex, func(aggr *TripleAggregate) {
+		referrer := ""
+		if _, ok := aggr.Subject.(rdf.Blank); ok && resourceIndex.References(aggr.SubjectStr) == 1 {
+			referrer = aggr.SubjectStr
+		}
+		for _, tr := range aggr.Triples {
+			if tr.Obj.Type() == rdf.TermBlank && resourceIndex.References(tr.Obj.String()) == 1 {
+				referrers[tr.Obj.String()] = referrer
+			}
+		}
+	})
+
+	// Follow the references back from each blank node, until reaching a node
+	// already visited, or a page. If the node reached is on the path, the
+	// nodes from there on form a cycle.
+	cyclic := make(map[string]bool)
+	visited := make(map[string]bool)
+	for blankLabel := range referrers {
+		path := []string{}
+		pathIndex := make(map[string]int)
+		for node := blankLabel; node != "" && !visited[node]; node = referrers[node] {
+			visited[node] = true
+			pathIndex[node] = len(path)
+			path = append(path, node)
+			if i, ok := pathIndex[referrers[node]]; ok {
+				for _, cycleNode := range path[i:] {
+					cyclic[cycleNode] = true
 				}
-			} else {
-				page.AddFactUnique(NewFact(propertyStr, valueStr))
+				break
 			}
 		}
+	}
+	return cyclic
+}
 
-		// Add Equivalent URI fact
-		equivURIFact := NewFact("Equivalent URI", aggr.Subject.String())
-		page.AddFactUnique(equivURIFact)
+// addRedirects adds the alternative titles of a resource to its page: the
+// values of the redirect properties (the titles of the resources, for URI
+// values), the values of the title properties that were not used for the
+// title, and the title made from the URI itself.
+func (p *TripleAggregateToWikiPageConverter) addRedirects(page *WikiPage, aggr *TripleAggregate, pageType int, resourceIndex ResourceIndex) {
+	nsPrefix := ""
+	if pageType == URITypePredicate {
+		nsPrefix = "Property:"
+	}
+	add := func(title string) {
+		if title != "" {
+			page.AddRedirectUnique(nsPrefix + title)
+		}
+	}
+	for _, tr := range aggr.Triples {
+		isRedirectProperty := containsString(p.conf.Redirects.Properties, tr.Pred.String())
+		if tr.Obj.Type() == rdf.TermLiteral && (isRedirectProperty || containsString(p.conf.TitleProperties, tr.Pred.String())) {
+			add(p.cleanTitle(tr.Obj.String()))
+		} else if tr.Obj.Type() == rdf.TermIRI && isRedirectProperty {
+			_, title := p.convertUriToWikiTitle(tr.Obj.String(), pageType, p.getAggregate(resourceIndex, tr.Obj.String()))
+			add(title)
+		}
+	}
+	if _, ok := aggr.Subject.(rdf.Blank); !ok {
+		add(p.resourceTitle(aggr.SubjectStr, pageType, nil))
+	}
+}
 
-		// Don't send predicates just yet (we want to gather facts about them,
-		// and send at the end) ...
-		if pageType == URITypePredicate {
-			if predPageIndex[page.Title] != nil {
-				// Add facts and categories to existing page
-				for _, fact := range page.Facts {
-					predPageIndex[page.Title].AddFactUnique(fact)
+// applySchema adds what is given by the rdfs:range and rdfs:domain of a
+// property to its page: "Has range category" for class ranges, "Allows
+// value" for enumerated (owl:oneOf) ranges, and the domain classes as the
+// categories (and thus templates) the property belongs to. It returns the
+// SMW type given by the range, or "" if there is none.
+func (p *TripleAggregateToWikiPageConverter) applySchema(aggr *TripleAggregate, page *WikiPage, resourceIndex ResourceIndex) string {
+	rangeType := ""
+	for _, tr := range aggr.Triples {
+		switch tr.Pred.String() {
+		case rangePropertyURI:
+			if values, valueType, ok := p.enumeratedValues(tr.Obj.String(), resourceIndex); ok {
+				for _, value := range values {
+					page.AddFactUnique(NewFact("Allows value", value))
 				}
-				for _, cat := range page.Categories {
-					predPageIndex[page.Title].AddCategoryUnique(cat)
+				if rangeType == "" {
+					rangeType = valueType
+				}
+			} else if tr.Obj.Type() == rdf.TermIRI {
+				smwType := rangeURIToSMWType(tr.Obj.String(), p.conf.DataTypes)
+				if smwType == smwTypePage {
+					_, catName := p.convertUriToWikiTitle(tr.Obj.String(), URITypeClass, p.getAggregate(resourceIndex, tr.Obj.String()))
+					rangeFact := NewFact("Has range category", "Category:"+catName)
+					rangeFact.DataType = smwTypePage
+					page.AddFactUnique(rangeFact)
+				}
+				if rangeType == "" {
+					rangeType = smwType
 				}
-			} else {
-				// If page does not exist, use the newly created one
-				predPageIndex[page.Title] = page
 			}
-		} else {
-			p.OutPage <- page
+		case domainPropertyURI:
+			if tr.Obj.Type() == rdf.TermIRI {
+				_, catName := p.convertUriToWikiTitle(tr.Obj.String(), URITypeClass, p.getAggregate(resourceIndex, tr.Obj.String()))
+				page.AddDomainUnique(NewCategory(catName))
+			}
 		}
 	}
+	return rangeType
+}
 
-	for _, predPage := range predPageIndex {
-		p.OutPage <- predPage
+// enumeratedValues returns the values of the enumeration (owl:oneOf list) of
+// the class or datatype node, and their SMW type, if node is an enumeration.
+func (p *TripleAggregateToWikiPageConverter) enumeratedValues(node string, resourceIndex ResourceIndex) ([]string, string, bool) {
+	aggr := p.getAggregate(resourceIndex, node)
+	if aggr == nil {
+		return nil, "", false
+	}
+	for _, tr := range aggr.Triples {
+		if tr.Pred.String() != oneOfPropertyURI {
+			continue
+		}
+		members, ok := p.readList(tr.Obj.String(), resourceIndex)
+		if !ok || len(members) == 0 {
+			return nil, "", false
+		}
+		values := []string{}
+		valueType := smwTypePage
+		if lit, ok := members[0].(rdf.Literal); ok {
+			valueType = rangeURIToSMWType(lit.DataType.String(), p.conf.DataTypes)
+		}
+		for _, member := range members {
+			if member.Type() == rdf.TermIRI {
+				_, title := p.convertUriToWikiTitle(member.String(), URITypeUndefined, p.getAggregate(resourceIndex, member.String()))
+				values = append(values, title)
+			} else {
+				values = append(values, normalizeValue(valueType, member.String()))
+			}
+		}
+		return values, valueType, true
 	}
+	return nil, "", false
 }
 
+// determineType returns the type of the resource with the triples in
+// uriAggr: a property or a class, if it has an rdf:type listed in the
+// property or category types, or else a property if it has an rdfs:range or
+// rdfs:domain, or a class if it is a subclass of another class.
 func (p *TripleAggregateToWikiPageConverter) determineType(uriAggr *TripleAggregate) int {
+	hasSchema := false
+	isSubClass := false
 	if uriAggr != nil {
 		if uriAggr.Triples != nil {
 			for _, tr := range uriAggr.Triples {
-				for _, propType := range propertyTypes {
+				switch tr.Pred.String() {
+				case rangePropertyURI, domainPropertyURI:
+					hasSchema = true
+				case subClassPropertyURI:
+					isSubClass = true
+				}
+				for _, propType := range p.conf.PropertyTypes {
 					if tr.Pred.String() == typePropertyURI && tr.Obj.String() == propType {
 						return URITypePredicate
 					}
 				}
-				for _, catType := range categoryTypes {
+				for _, catType := range p.conf.CategoryTypes {
 					if tr.Pred.String() == typePropertyURI && tr.Obj.String() == catType {
 						return URITypeClass
 					}
@@ -191,15 +672,38 @@ func (p *TripleAggregateToWikiPageConverter) determineType(uriAggr *TripleAggreg
 			}
 		}
 	}
+	if hasSchema {
+		return URITypePredicate
+	}
+	if isSubClass {
+		return URITypeClass
+	}
 	return URITypeUndefined
 }
 
 // For properties, the factTitle and pageTitle will be different (The page
 // title including the "Property:" prefix), while for normal pages, they will
-// be the same.
-func (p *TripleAggregateToWikiPageConverter) convertUriToWikiTitle(uri string, uriType int, resourceIndex *map[string]*TripleAggregate) (pageTitle string, factTitle string) {
+// be the same. aggr holds the triples about the resource, if known, and may
+// be nil.
+func (p *TripleAggregateToWikiPageConverter) convertUriToWikiTitle(uri string, uriType int, aggr *TripleAggregate) (pageTitle string, factTitle string) {
+	factTitle = p.resourceTitle(uri, uriType, aggr)
+
+	// Resources sharing their title with other resources get the title given
+	// by the title registry
+	if p.titles != nil {
+		if title, ok := p.titles.titles[titleKey(uri, uriType)]; ok {
+			factTitle = title
+		}
+	}
+
+	return wikiPageTitle(factTitle, uriType), factTitle
+}
 
-	aggr := (*resourceIndex)[uri]
+// resourceTitle returns the title of the resource uri, as convertUriToWikiTitle
+// does, but without the namespace prefix, and without looking it up in the
+// title registry, so that it is the title the resource would have without
+// title collisions.
+func (p *TripleAggregateToWikiPageConverter) resourceTitle(uri string, uriType int, aggr *TripleAggregate) (factTitle string) {
 
 	// Conversion strategies:
 	// 1. Existing wiki title (in wiki, or cache)
@@ -208,80 +712,157 @@ func (p *TripleAggregateToWikiPageConverter) convertUriToWikiTitle(uri string, u
 		factTitle = p.findTitleInTriples(aggr.Triples)
 	}
 
+	// Blank nodes without a title get a title derived from their triples,
+	// which stays the same between runs, unlike their labels
+	if factTitle == "" && isBlankNode(uri, aggr) {
+		factTitle = skolemTitle(uri, aggr)
+	}
+
 	// 3. Shorten URI namespace to alias (e.g. http://purl.org/dc -> dc:)
-	//    (Does this apply for properties only?)
+	if factTitle == "" && p.abbreviate(uri, uriType) {
+		if prefix, name, ok := p.Prefixes.Abbreviate(uri); ok {
+			factTitle = prefix + p.conf.AbbreviationSeparator + name
+		}
+	}
 
 	// 4. Remove namespace, keep only local part of URL (Split on '/' or '#')
 	if factTitle == "" {
-		bits := str.Split(uri, "#")
-		lastBit := bits[len(bits)-1]
-		bits = str.Split(lastBit, "/")
-		lastBit = bits[len(bits)-1]
-		factTitle = lastBit
-	}
-
-	// Clean up strange characters
-	factTitle = str.Replace(factTitle, "[", "(", -1)
-	factTitle = str.Replace(factTitle, "]", ")", -1)
-	factTitle = str.Replace(factTitle, "{", "(", -1)
-	factTitle = str.Replace(factTitle, "}", ")", -1)
-	factTitle = str.Replace(factTitle, "|", " ", -1)
-	factTitle = str.Replace(factTitle, "#", " ", -1)
-	factTitle = str.Replace(factTitle, "<", "less than", -1)
-	factTitle = str.Replace(factTitle, ">", "greater than", -1)
-	factTitle = str.Replace(factTitle, "?", " ", -1)
-	factTitle = str.Replace(factTitle, "&", " ", -1)
-	factTitle = str.Replace(factTitle, ",", " ", -1) // Can't allow comma's as we use it as a separator in template variables
-	factTitle = str.Replace(factTitle, ".", " ", -1)
-	factTitle = str.Replace(factTitle, "=", "-", -1)
+		factTitle = localName(uri)
+	}
 
-	// Clean up according to regexes
-	for _, r := range p.cleanUpRegexes {
-		factTitle = r.ReplaceAllString(factTitle, "")
+	factTitle = p.cleanTitle(factTitle)
+	if factTitle == "" {
+		factTitle = "Untitled " + uriHash(uri)
 	}
+	return factTitle
+}
 
-	// Limit to max 255 chars (due to MediaWiki limitation)
-	titleIsShortened := false
-	for len(factTitle) >= 250 {
-		factTitle = removeLastWord(factTitle)
-		titleIsShortened = true
+// wikiPageTitle returns the page title of a resource of the type uriType
+// titled factTitle, with the namespace prefix of its type.
+func wikiPageTitle(factTitle string, uriType int) string {
+	if uriType == URITypePredicate {
+		return "Property:" + factTitle
+	} else if uriType == URITypeClass {
+		return "Category:" + factTitle
 	}
+	return factTitle
+}
 
-	if titleIsShortened {
-		factTitle += " ..."
+// cleanTitle makes a valid title of title, that can also be used in fact
+// values and template calls.
+func (p *TripleAggregateToWikiPageConverter) cleanTitle(title string) string {
+	// Can't allow comma's as we use it as a separator in template variables,
+	// nor equal signs, which are replaced in fact values
+	title = str.Replace(title, ",", " ", -1)
+	title = str.Replace(title, "=", "-", -1)
+
+	// Clean up according to regexes
+	for _, r := range p.cleanUpRegexes {
+		title = r.ReplaceAllString(title, "")
 	}
 
-	factTitle = p.upperCaseFirst(factTitle)
+	// Make a valid MediaWiki title of it
+	return p.titleNormalizer.Normalize(title)
+}
 
-	if uriType == URITypePredicate {
-		pageTitle = "Property:" + factTitle
-	} else if uriType == URITypeClass {
-		pageTitle = "Category:" + factTitle
-	} else {
-		pageTitle = factTitle
+// abbreviate tells whether the title of uri is to be made from its namespace
+// prefix and local name, according to the abbreviation policy.
+func (p *TripleAggregateToWikiPageConverter) abbreviate(uri string, uriType int) bool {
+	switch p.conf.AbbreviationPolicy {
+	case AbbreviateAll:
+		return true
+	case AbbreviateProperties:
+		return uriType == URITypePredicate
+	case AbbreviateOnCollision:
+		return p.collidingNames[p.titleNormalizer.Normalize(localName(uri))]
+	}
+	return false
+}
+
+// findCollidingLocalNames returns the local names (as used in titles) shared
+// by more than one of the URIs in the index.
+func (p *TripleAggregateToWikiPageConverter) findCollidingLocalNames(resourceIndex ResourceIndex) map[string]bool {
+	uris := make(map[string]string)
+	colliding := make(map[string]bool)
+	addURI := func(term rdf.Term) {
+		if term.Type() != rdf.TermIRI {
+			return
+		}
+		name := p.titleNormalizer.Normalize(localName(term.String()))
+		if firstURI, ok := uris[name]; !ok {
+			uris[name] = term.String()
+		} else if firstURI != term.String() {
+			colliding[name] = true
+		}
 	}
+	p.eachAggregate(resourceIndex, func(aggr *TripleAggregate) {
+		for _, tr := range aggr.Triples {
+			addURI(tr.Subj)
+			addURI(tr.Pred)
+			addURI(tr.Obj)
+		}
+	})
+	return colliding
+}
 
-	return pageTitle, factTitle
+// localName returns the local part of uri, after the last '#' or '/'.
+func localName(uri string) string {
+	bits := str.Split(uri, "#")
+	lastBit := bits[len(bits)-1]
+	bits = str.Split(lastBit, "/")
+	return bits[len(bits)-1]
 }
 
+// findTitleInTriples returns the value of the first title property found in
+// triples, in order of priority of the title properties. Among several values
+// of the same title property, the one in the most preferred language is
+// picked.
 func (p *TripleAggregateToWikiPageConverter) findTitleInTriples(triples []rdf.Triple) string {
-	for _, titleProp := range titleProperties {
+	return p.findPreferredValue(triples, p.conf.TitleProperties)
+}
+
+// findPreferredValue returns the value of the first of the properties props
+// found in triples, picking the one in the most preferred language among
+// several values of the same property, or "" if none is found.
+func (p *TripleAggregateToWikiPageConverter) findPreferredValue(triples []rdf.Triple, props []string) string {
+	for _, prop := range props {
+		value, bestRank := "", -1
 		for _, tr := range triples {
-			if tr.Pred.String() == titleProp {
-				return tr.Obj.String()
+			if tr.Pred.String() == prop {
+				rank := languageRank(literalLang(tr.Obj), p.conf.Languages)
+				if bestRank < 0 || rank < bestRank {
+					value, bestRank = tr.Obj.String(), rank
+				}
 			}
 		}
+		if bestRank >= 0 {
+			return value
+		}
 	}
 	return ""
 }
 
-func (p *TripleAggregateToWikiPageConverter) countSuperCategories(tr rdf.Triple, ri *map[string]*TripleAggregate) int {
-	catPage := (*ri)[tr.Obj.String()]
+// countSuperCategories returns the length of the longest chain of types and
+// super classes above the class that is the object of tr. Classes already on
+// the chain are not followed again, so that cycles of subclasses end.
+func (p *TripleAggregateToWikiPageConverter) countSuperCategories(tr rdf.Triple, ri ResourceIndex) int {
+	return p.countSuperCategoriesOnPath(tr, ri, make(map[string]bool))
+}
+
+func (p *TripleAggregateToWikiPageConverter) countSuperCategoriesOnPath(tr rdf.Triple, ri ResourceIndex, onPath map[string]bool) int {
+	catStr := tr.Obj.String()
+	if onPath[catStr] {
+		return 0
+	}
+	onPath[catStr] = true
+	defer delete(onPath, catStr)
+
+	catPage := p.getAggregate(ri, catStr)
 	topSuperCatsCnt := 0
 	if catPage != nil {
 		for _, subTr := range catPage.Triples {
 			if subTr.Pred.String() == typePropertyURI || subTr.Pred.String() == subClassPropertyURI {
-				superCatsCnt := p.countSuperCategories(subTr, ri) + 1
+				superCatsCnt := p.countSuperCategoriesOnPath(subTr, ri, onPath) + 1
 				if superCatsCnt > topSuperCatsCnt {
 					topSuperCatsCnt = superCatsCnt
 				}
@@ -291,16 +872,33 @@ func (p *TripleAggregateToWikiPageConverter) countSuperCategories(tr rdf.Triple,
 	return topSuperCatsCnt
 }
 
-func (p *TripleAggregateToWikiPageConverter) upperCaseFirst(inStr string) string {
-	var outStr string
-	if inStr != "" {
-		outStr = str.ToUpper(inStr[0:1]) + inStr[1:]
+// isBlankNode tells whether uri is the label of a blank node, which is only
+// known when its aggregate aggr is.
+func isBlankNode(uri string, aggr *TripleAggregate) bool {
+	if aggr == nil {
+		return false
 	}
-	return outStr
+	_, ok := aggr.Subject.(rdf.Blank)
+	return ok
 }
 
-func removeLastWord(inStr string) string {
-	bits := str.Split(inStr, " ")
-	outStr := str.Join(append(bits[:len(bits)-1]), " ")
-	return outStr
+// skolemTitle returns a stable title for a blank node, made from a hash of
+// its triples. References to other blank nodes are left out of the hash,
+// since their labels change between runs. Blank nodes whose triples are not
+// known are titled by their label.
+func skolemTitle(blankLabel string, aggr *TripleAggregate) string {
+	if len(aggr.Triples) == 0 {
+		return "Blank node " + blankLabel
+	}
+	lines := []string{}
+	for _, tr := range aggr.Triples {
+		obj := tr.Obj.Serialize(rdf.NTriples)
+		if tr.Obj.Type() == rdf.TermBlank {
+			obj = "_:"
+		}
+		lines = append(lines, tr.Pred.Serialize(rdf.NTriples)+" "+obj)
+	}
+	sort.Strings(lines)
+	hash := sha1.Sum([]byte(str.Join(lines, "\n")))
+	return "Blank node " + hex.EncodeToString(hash[:])[:12]
 }
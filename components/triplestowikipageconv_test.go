@@ -1,15 +1,23 @@
 package components
 
 import (
-	"github.com/flowbase/flowbase"
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/flowbase/flowbase"
+	"github.com/knakk/rdf"
 )
 
-// TestNewTripleAggregateToWikiPageConverter tests NewTripleAggregateToWikiPageConverter()
+// TestNewTripleAggregateToWikiPageConverter tests NewTripleAggregateToWikiPageConverter(DefaultConfig())
 func TestNewTripleAggregateToWikiPageConverter(t *testing.T) {
 	flowbase.InitLogDebug()
 
-	mxc := NewTripleAggregateToWikiPageConverter()
+	mxc := NewTripleAggregateToWikiPageConverter(DefaultConfig())
 
 	if mxc.InAggregate == nil {
 		t.Error("InAggregate is not initialized")
@@ -24,3 +32,690 @@ func TestNewTripleAggregateToWikiPageConverter(t *testing.T) {
 		t.Error("cleanUpRegexes is not initialized")
 	}
 }
+
+// convertTestTriples runs a TripleAggregateToWikiPageConverter with workers
+// workers on the N-Triples data in testData, aggregated per subject in input
+// order, and returns the resulting pages.
+func convertTestTriples(t *testing.T, testData string, workers int, keepOrder bool) []*WikiPage {
+	return convertTestTriplesWith(t, testData, DefaultConfig(), workers, keepOrder)
+}
+
+// convertTestTriplesWithConfig is like convertTestTriples, with one worker
+// and the configuration conf.
+func convertTestTriplesWithConfig(t *testing.T, testData string, conf *Config) []*WikiPage {
+	return convertTestTriplesWith(t, testData, conf, 1, false)
+}
+
+func convertTestTriplesWith(t *testing.T, testData string, conf *Config, workers int, keepOrder bool) []*WikiPage {
+	conv := NewTripleAggregateToWikiPageConverter(conf)
+	conv.Workers = workers
+	conv.KeepOrder = keepOrder
+	return convertTestTriplesWithConverter(t, testData, conv)
+}
+
+// convertTestTriplesWithConverter is like convertTestTriples, using the
+// converter conv.
+func convertTestTriplesWithConverter(t *testing.T, testData string, conv *TripleAggregateToWikiPageConverter) []*WikiPage {
+	triples, err := rdf.NewTripleDecoder(strings.NewReader(testData), rdf.NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal("Could not decode n-triples test data: ", err.Error())
+	}
+	idx := NewMemResourceIndex()
+	aggrs := []*TripleAggregate{}
+	for _, tr := range triples {
+		idx.Add(tr)
+		if len(aggrs) == 0 || aggrs[len(aggrs)-1].SubjectStr != tr.Subj.String() {
+			aggrs = append(aggrs, NewTripleAggregate(tr.Subj, []rdf.Triple{}))
+		}
+		aggrs[len(aggrs)-1].Triples = append(aggrs[len(aggrs)-1].Triples, tr)
+	}
+
+	conv.InIndex <- idx
+	go func() {
+		defer close(conv.InAggregate)
+		for _, aggr := range aggrs {
+			conv.InAggregate <- aggr
+		}
+	}()
+	go conv.Run()
+
+	pages := []*WikiPage{}
+	for page := range conv.OutPage {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func TestTripleAggregateToWikiPageConverterWorkers(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Person> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Class> .
+<http://example.org/knows> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#ObjectProperty> .
+<http://example.org/knows> <http://www.w3.org/2000/01/rdf-schema#label> "knows" .
+`
+	for i := 1; i <= 50; i++ {
+		testData += fmt.Sprintf("<http://example.org/p%d> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Person> .\n", i)
+		testData += fmt.Sprintf("<http://example.org/p%d> <http://www.w3.org/2000/01/rdf-schema#label> \"Person %d\" .\n", i, i)
+		testData += fmt.Sprintf("<http://example.org/p%d> <http://example.org/knows> <http://example.org/p%d> .\n", i, i%50+1)
+		testData += fmt.Sprintf("<http://example.org/p%d> <http://example.org/age> \"%d\"^^<http://www.w3.org/2001/XMLSchema#integer> .\n", i, i)
+	}
+
+	expected := convertTestTriples(t, testData, 1, false)
+	if len(expected) != 55 {
+		t.Fatalf("Wrong number of pages with one worker (Expected 55, got %d)", len(expected))
+	}
+
+	ordered := convertTestTriples(t, testData, 8, true)
+	if len(ordered) != len(expected) {
+		t.Fatalf("Wrong number of pages with 8 workers (Expected %d, got %d)", len(expected), len(ordered))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(ordered[i], expected[i]) {
+			t.Errorf("Page %d differs with 8 workers and KeepOrder:\n%v\nExpected:\n%v", i, ordered[i], expected[i])
+		}
+	}
+
+	unordered := convertTestTriples(t, testData, 8, false)
+	titles := map[string]bool{}
+	for _, page := range unordered {
+		titles[page.Title] = true
+	}
+	for _, page := range expected {
+		if !titles[page.Title] {
+			t.Errorf("Page %s missing with 8 workers", page.Title)
+		}
+	}
+
+	for _, page := range expected {
+		if page.Title == "Property:Knows" {
+			if len(page.Facts) != 3 || page.Facts[0].Property != "Label" || page.Facts[1].Property != "Equivalent URI" || page.Facts[2].Value != "Page" {
+				t.Errorf("Property page facts not merged correctly: %v", page.Facts)
+			}
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterDataTypes(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/alice> <http://example.org/born> "1969-07-20T20:17:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+<http://example.org/alice> <http://example.org/active> "1"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<http://example.org/alice> <http://example.org/location> "POINT(13.405 52.52)"^^<http://www.opengis.net/ont/geosparql#wktLiteral> .
+<http://example.org/alice> <http://example.org/mbox> <mailto:alice@example.org> .
+`
+	pages := convertTestTriples(t, testData, 1, false)
+
+	expectedFacts := map[string]string{
+		"Born":     "1969-07-20T20:17:00",
+		"Active":   "true",
+		"Location": "52.52, 13.405",
+		"Mbox":     "alice@example.org",
+	}
+	expectedTypes := map[string]string{
+		"Property:Born":     "Date",
+		"Property:Active":   "Boolean",
+		"Property:Location": "Geographic coordinate",
+		"Property:Mbox":     "Email",
+	}
+	checked := 0
+	for _, page := range pages {
+		if page.Title == "Alice" {
+			checked++
+			for _, fact := range page.Facts {
+				if expected, ok := expectedFacts[fact.Property]; ok && fact.Value != expected {
+					t.Errorf("Wrong value for %s (Expected %s, got %s)", fact.Property, expected, fact.Value)
+				}
+			}
+		} else if expected, ok := expectedTypes[page.Title]; ok {
+			checked++
+			if len(page.Facts) != 1 || page.Facts[0].Property != "Has type" || page.Facts[0].Value != expected {
+				t.Errorf("Wrong type facts on %s (Expected Has type %s): %v", page.Title, expected, page.Facts)
+			}
+		}
+	}
+	if checked != 5 {
+		t.Errorf("Expected 5 pages to check, found %d", checked)
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterSchema(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Person> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Class> .
+<http://example.org/knows> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#ObjectProperty> .
+<http://example.org/knows> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+<http://example.org/knows> <http://www.w3.org/2000/01/rdf-schema#range> <http://example.org/Person> .
+<http://example.org/height> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#DatatypeProperty> .
+<http://example.org/height> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+<http://example.org/height> <http://www.w3.org/2000/01/rdf-schema#range> <http://www.w3.org/2001/XMLSchema#decimal> .
+<http://example.org/size> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#DatatypeProperty> .
+<http://example.org/size> <http://www.w3.org/2000/01/rdf-schema#range> _:sizes .
+_:sizes <http://www.w3.org/2002/07/owl#oneOf> _:l1 .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "S" .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l2 .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "M" .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+<http://example.org/weight> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Property> .
+<http://example.org/weight> <http://www.w3.org/2000/01/rdf-schema#range> <http://www.w3.org/2001/XMLSchema#decimal> .
+<http://example.org/weight> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+<http://example.org/nickname> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+`
+	pages := map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, testData, 1, false) {
+		pages[page.Title] = page
+	}
+
+	hasFact := func(title string, property string, value string) bool {
+		if pages[title] == nil {
+			return false
+		}
+		for _, fact := range pages[title].Facts {
+			if fact.Property == property && fact.Value == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	expectedFacts := [][3]string{
+		{"Property:Knows", "Has type", "Page"},
+		{"Property:Knows", "Has range category", "Category:Person"},
+		{"Property:Height", "Has type", "Number"},
+		{"Property:Size", "Has type", "Text"},
+		{"Property:Size", "Allows value", "S"},
+		{"Property:Size", "Allows value", "M"},
+		{"Property:Weight", "Has type", "Number"},
+	}
+	for _, f := range expectedFacts {
+		if !hasFact(f[0], f[1], f[2]) {
+			t.Errorf("Fact [[%s::%s]] missing on page %s", f[1], f[2], f[0])
+		}
+	}
+	for _, title := range []string{"Property:Knows", "Property:Height", "Property:Weight", "Property:Nickname"} {
+		if pages[title] == nil || len(pages[title].Domains) != 1 || pages[title].Domains[0].Name != "Person" {
+			t.Errorf("Domain Person missing on page %s", title)
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterBlankNodes(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Alice> <http://example.org/address> _:addr .
+<http://example.org/Alice> <http://example.org/memberOf> _:club .
+_:addr <http://example.org/street> "Main Street 1" .
+_:addr <http://example.org/geo> _:point .
+_:point <http://example.org/lat> "59.8" .
+<http://example.org/Bob> <http://example.org/memberOf> _:club .
+_:club <http://example.org/name> "Chess club" .
+`
+	pages := map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, testData, 1, false) {
+		pages[page.Title] = page
+	}
+
+	alice := pages["Alice"]
+	if alice == nil {
+		t.Fatal("Page Alice missing")
+	}
+	if len(alice.Subobjects) != 2 {
+		t.Fatalf("Expected 2 subobjects on page Alice, got %d", len(alice.Subobjects))
+	}
+	addr, point := alice.Subobjects[0], alice.Subobjects[1]
+	if addr.Name != "Address 1" || len(addr.Facts) != 2 || addr.Facts[0].Value != "Main Street 1" || addr.Facts[1].Value != "Alice#Geo 2" {
+		t.Errorf("Wrong subobject for the address: %v %v", addr.Name, addr.Facts)
+	}
+	if point.Name != "Geo 2" || len(point.Facts) != 1 || point.Facts[0].Value != "59.8" {
+		t.Errorf("Wrong subobject for the nested blank node: %v %v", point.Name, point.Facts)
+	}
+
+	memberOf := func(pages map[string]*WikiPage, title string) string {
+		if pages[title] == nil {
+			return ""
+		}
+		for _, fact := range pages[title].Facts {
+			if fact.Property == "MemberOf" {
+				return fact.Value
+			}
+		}
+		return ""
+	}
+	clubTitle := memberOf(pages, "Alice")
+	if !strings.HasPrefix(clubTitle, "Blank node ") {
+		t.Errorf("Shared blank node did not get a skolemized title: %s", clubTitle)
+	}
+	if bobClub := memberOf(pages, "Bob"); bobClub != clubTitle {
+		t.Errorf("Pages link to different titles for the same blank node: %s and %s", clubTitle, bobClub)
+	}
+	relabeledPages := map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, strings.Replace(testData, "_:club", "_:c42", -1), 1, false) {
+		relabeledPages[page.Title] = page
+	}
+	if relabeledClub := memberOf(relabeledPages, "Alice"); relabeledClub != clubTitle {
+		t.Errorf("Skolemized title depends on blank node label: %s and %s", clubTitle, relabeledClub)
+	}
+	if pages[clubTitle] == nil {
+		t.Errorf("Page %s missing for the shared blank node", clubTitle)
+	}
+	for title := range pages {
+		if strings.HasPrefix(title, "Addr") || strings.HasPrefix(title, "Point") {
+			t.Errorf("Got page %s for inlined blank node", title)
+		}
+	}
+}
+
+// TestTripleAggregateToWikiPageConverterBlankNodeCycles tests that blank
+// nodes referenced once, but only from each other in a cycle, get pages
+// rather than being dropped, and that blank nodes they refer to are inlined
+func TestTripleAggregateToWikiPageConverterBlankNodeCycles(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+_:c1 <http://example.org/next> _:c2 .
+_:c1 <http://example.org/name> "One" .
+_:c2 <http://example.org/next> _:c1 .
+_:c2 <http://example.org/name> "Two" .
+_:c2 <http://example.org/detail> _:d .
+_:d <http://example.org/name> "Detail" .
+_:self <http://example.org/next> _:self .
+`
+	conf := DefaultConfig()
+	conf.TitleProperties = []string{"http://example.org/name"}
+	pages := map[string]*WikiPage{}
+	for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+		pages[page.Title] = page
+	}
+
+	for _, title := range []string{"One", "Two"} {
+		if pages[title] == nil {
+			t.Errorf("Page %s missing for blank node in a cycle", title)
+		}
+	}
+	if pages["Detail"] != nil || pages["Two"] == nil || len(pages["Two"].Subobjects) != 1 {
+		t.Error("Blank node referenced from a cycle not inlined")
+	}
+	selfPages := 0
+	for title := range pages {
+		if strings.HasPrefix(title, "Blank node ") {
+			selfPages++
+		}
+	}
+	if selfPages != 1 {
+		t.Errorf("Expected one page for the blank node referring to itself, got %d", selfPages)
+	}
+
+	// Without titles, the blank nodes of the cycle get skolemized titles from
+	// the same triples, and are to be told apart
+	pages = map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, `
+_:c1 <http://example.org/next> _:c2 .
+_:c2 <http://example.org/next> _:c1 .
+`, 1, false) {
+		pages[page.Title] = page
+	}
+	next := map[string]string{}
+	for title, page := range pages {
+		for _, fact := range page.Facts {
+			if fact.Property == "Next" {
+				next[title] = fact.Value
+			}
+		}
+	}
+	if len(next) != 2 {
+		t.Fatalf("Expected 2 pages with distinct titles for the blank nodes of the cycle, got: %v", next)
+	}
+	for title, nextTitle := range next {
+		if nextTitle == title || next[nextTitle] != title {
+			t.Errorf("Wrong link from %s to %s for the blank nodes of the cycle", title, nextTitle)
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterCollections(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Book> <http://example.org/authors> _:l1 .
+<http://example.org/Book> <http://example.org/chapters> _:seq .
+<http://example.org/Book> <http://example.org/editors> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "Zed" .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l2 .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "Adam" .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l3 .
+_:l3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "Zed" .
+_:l3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+_:seq <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Seq> .
+_:seq <http://www.w3.org/1999/02/22-rdf-syntax-ns#_2> <http://example.org/Ending> .
+_:seq <http://www.w3.org/1999/02/22-rdf-syntax-ns#_1> <http://example.org/Beginning> .
+`
+	pages := convertTestTriples(t, testData, 1, false)
+
+	var book *WikiPage
+	for _, page := range pages {
+		if page.Title == "Book" {
+			book = page
+		} else if page.Type != URITypePredicate {
+			t.Errorf("Got page %s for a collection node", page.Title)
+		}
+	}
+	if book == nil {
+		t.Fatal("Page Book missing")
+	}
+
+	values := map[string][]string{}
+	for _, fact := range book.Facts {
+		if fact.ListIndex != 0 && fact.ListIndex != len(values[fact.Property])+1 {
+			t.Errorf("Wrong list index %d for value %s of %s", fact.ListIndex, fact.Value, fact.Property)
+		}
+		values[fact.Property] = append(values[fact.Property], fact.Value)
+	}
+	if !reflect.DeepEqual(values["Authors"], []string{"Zed", "Adam", "Zed"}) {
+		t.Errorf("Wrong values of list: %v", values["Authors"])
+	}
+	if !reflect.DeepEqual(values["Chapters"], []string{"Beginning", "Ending"}) {
+		t.Errorf("Wrong values of container: %v", values["Chapters"])
+	}
+	if len(values["Editors"]) != 0 {
+		t.Errorf("Got values for empty list: %v", values["Editors"])
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterAbbreviations(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Alice> <http://purl.org/dc/elements/1.1/title> <http://example.org/Boss> .
+<http://example.org/Alice> <http://example.org/vocab#title> "Dr" .
+<http://example.org/Alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/Bob> .
+`
+	tests := map[string][]string{
+		AbbreviateNone:        {"Title", "Title", "Knows", "Boss"},
+		AbbreviateProperties:  {"Dc:title", "Voc:title", "Foaf:knows", "Boss"},
+		AbbreviateAll:         {"Dc:title", "Voc:title", "Foaf:knows", "Ex:Boss"},
+		AbbreviateOnCollision: {"Dc:title", "Voc:title", "Knows", "Boss"},
+	}
+	for policy, expected := range tests {
+		conf := DefaultConfig()
+		conf.AbbreviationPolicy = policy
+		conf.TitleCollisionStrategy = TitleCollisionNone
+		conf.NamespaceAbbreviations["http://example.org/vocab#"] = "voc"
+		conf.NamespaceAbbreviations["http://example.org/"] = "ex"
+		var alice *WikiPage
+		for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+			if page.Type == URITypeUndefined {
+				alice = page
+			}
+		}
+		if alice == nil || len(alice.Facts) < 3 {
+			t.Fatalf("Page for Alice missing with policy %s", policy)
+		}
+		got := []string{alice.Facts[0].Property, alice.Facts[1].Property, alice.Facts[2].Property, alice.Facts[0].Value}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Wrong titles with policy %s (Expected %v, got %v)", policy, expected, got)
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterTitleCollisions(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/planets/Mercury> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Planet> .
+<http://example.org/elements/Mercury> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Element> .
+<http://example.org/gods/mercury> <http://www.w3.org/2000/01/rdf-schema#label> "Mercury" .
+<http://example.org/Venus> <http://example.org/orbits> <http://example.org/Sun> .
+`
+	tests := map[string][]string{
+		TitleCollisionNone: {"Mercury", "Mercury", "Mercury"},
+		// No prefixes are known for the namespaces, so their last parts are used
+		TitleCollisionNamespace: {"Mercury (elements)", "Mercury (gods)", "Mercury (planets)"},
+		TitleCollisionClass:     {"Mercury (" + uriHash("http://example.org/gods/mercury") + ")", "Mercury (Element)", "Mercury (Planet)"},
+		TitleCollisionHash:      {"Mercury (" + uriHash("http://example.org/elements/Mercury") + ")", "Mercury (" + uriHash("http://example.org/gods/mercury") + ")", "Mercury (" + uriHash("http://example.org/planets/Mercury") + ")"},
+	}
+	for strategy, expected := range tests {
+		conf := DefaultConfig()
+		conf.TitleCollisionStrategy = strategy
+		conv := NewTripleAggregateToWikiPageConverter(conf)
+		titles := []string{}
+		for _, page := range convertTestTriplesWithConverter(t, testData, conv) {
+			if strings.HasPrefix(page.Title, "Mercury") {
+				titles = append(titles, page.Title)
+			}
+			if page.Title == "Venus" && (len(page.Facts) == 0 || page.Facts[0].Value != "Sun") {
+				t.Errorf("Title of resource without collision changed with strategy %s: %v", strategy, page.Facts)
+			}
+		}
+		sort.Strings(titles)
+		if !reflect.DeepEqual(titles, expected) {
+			t.Errorf("Wrong titles with strategy %s (Expected %v, got %v)", strategy, expected, titles)
+		}
+
+		collisions := conv.TitleCollisions()
+		if strategy == TitleCollisionNone {
+			if len(collisions) != 0 {
+				t.Errorf("Got collisions with strategy none: %v", collisions)
+			}
+			continue
+		}
+		if len(collisions) != 1 || collisions[0].Title != "Mercury" || len(collisions[0].URIs) != 3 {
+			t.Errorf("Wrong collisions with strategy %s: %v", strategy, collisions)
+		}
+	}
+}
+
+// TestTripleAggregateToWikiPageConverterTitleCollisionsInUse tests that
+// titles given to resolve collisions are not titles of other resources, and
+// that resources in the same namespace are told apart by their local names
+func TestTripleAggregateToWikiPageConverterTitleCollisionsInUse(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/planets/Mercury> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Planet> .
+<http://example.org/elements/Mercury> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Element> .
+<http://example.org/other/m1> <http://www.w3.org/2000/01/rdf-schema#label> "Mercury (Planet)" .
+<http://example.org/people/bob> <http://www.w3.org/2000/01/rdf-schema#label> "Bob" .
+<http://example.org/people/bob2> <http://www.w3.org/2000/01/rdf-schema#label> "Bob" .
+`
+	tests := map[string][]string{
+		TitleCollisionNamespace: {"Bob (bob)", "Bob (bob2)", "Mercury (Planet)", "Mercury (elements)", "Mercury (planets)"},
+		TitleCollisionClass:     {"Bob (" + uriHash("http://example.org/people/bob") + ")", "Bob (" + uriHash("http://example.org/people/bob2") + ")", "Mercury (" + uriHash("http://example.org/planets/Mercury") + ")", "Mercury (Element)", "Mercury (Planet)"},
+	}
+	for strategy, expected := range tests {
+		conf := DefaultConfig()
+		conf.TitleCollisionStrategy = strategy
+		titles := []string{}
+		for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+			if page.Type == URITypeUndefined {
+				titles = append(titles, page.Title)
+			}
+		}
+		sort.Strings(titles)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(titles, expected) {
+			t.Errorf("Wrong titles with strategy %s (Expected %v, got %v)", strategy, expected, titles)
+		}
+	}
+}
+
+// TestTripleAggregateToWikiPageConverterSubClassCycle tests that classes that
+// are subclasses of each other do not make the class strategy loop
+func TestTripleAggregateToWikiPageConverterSubClassCycle(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/A> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example.org/B> .
+<http://example.org/B> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example.org/A> .
+<http://example.org/x1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/A> .
+<http://example.org/x1> <http://www.w3.org/2000/01/rdf-schema#label> "X" .
+<http://example.org/x2> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/B> .
+<http://example.org/x2> <http://www.w3.org/2000/01/rdf-schema#label> "X" .
+`
+	conf := DefaultConfig()
+	conf.TitleCollisionStrategy = TitleCollisionClass
+	titles := []string{}
+	for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+		if page.Type == URITypeUndefined {
+			titles = append(titles, page.Title)
+		}
+	}
+	sort.Strings(titles)
+	if expected := []string{"X (A)", "X (B)"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Wrong titles with subclass cycle (Expected %v, got %v)", expected, titles)
+	}
+}
+
+func TestWriteTitleCollisionReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteTitleCollisionReport(buf, []*TitleCollision{
+		{"Mercury", []string{"http://example.org/a/Mercury", "http://example.org/b/Mercury"}, []string{"Mercury (a)", "Mercury (b)"}},
+	})
+	expected := "title\turi\tresolved title\n" +
+		"Mercury\thttp://example.org/a/Mercury\tMercury (a)\n" +
+		"Mercury\thttp://example.org/b/Mercury\tMercury (b)\n"
+	if err != nil || buf.String() != expected {
+		t.Errorf("Wrong report (Expected %q, got %q)", expected, buf.String())
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterTitleNormalization(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	long := strings.Repeat("Größe ", 60)
+	testData := `
+<http://example.org/a> <http://www.w3.org/2000/01/rdf-schema#label> "Talk:Back" .
+<http://example.org/b> <http://www.w3.org/2000/01/rdf-schema#label> "AC/DC_band" .
+<http://example.org/c> <http://www.w3.org/2000/01/rdf-schema#label> "` + long + `" .
+<http://example.org/d> <http://www.w3.org/2000/01/rdf-schema#label> "St. Louis" .
+<http://example.org/e> <http://www.w3.org/2000/01/rdf-schema#label> "iPod" .
+`
+	for _, capitalLinks := range []bool{true, false} {
+		conf := DefaultConfig()
+		conf.CapitalLinks = capitalLinks
+		titles := map[string]bool{}
+		for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+			titles[page.Title] = true
+			if len(page.Title) > 255 || !utf8.ValidString(page.Title) {
+				t.Errorf("Invalid title: %q", page.Title)
+			}
+		}
+		iPod := "IPod"
+		if !capitalLinks {
+			iPod = "iPod"
+		}
+		for _, title := range []string{"Talk - Back", "AC-DC band", "St. Louis", iPod} {
+			if !titles[title] {
+				t.Errorf("Title %s missing (capitalLinks: %v): %v", title, capitalLinks, titles)
+			}
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterRedirects(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Q308> <http://www.w3.org/2000/01/rdf-schema#label> "Mercury"@en .
+<http://example.org/Q308> <http://www.w3.org/2000/01/rdf-schema#label> "Merkur"@de .
+<http://example.org/Q308> <http://www.w3.org/2004/02/skos/core#altLabel> "Quicksilver planet" .
+<http://example.org/Q308> <http://www.w3.org/2002/07/owl#sameAs> <http://dbpedia.org/resource/Mercury_(planet)> .
+<http://example.org/Q309> <http://example.org/name> "Venus" .
+`
+	conf := DefaultConfig()
+	conf.Languages = []string{"en"}
+	pages := map[string]*WikiPage{}
+	for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+		pages[page.Title] = page
+	}
+
+	mercury := pages["Mercury"]
+	if mercury == nil {
+		t.Fatalf("Page Mercury missing: %v", pages)
+	}
+	expected := []string{"Merkur", "Quicksilver planet", "Mercury (planet)", "Q308"}
+	if strings.Join(mercury.Redirects, "|") != strings.Join(expected, "|") {
+		t.Errorf("Wrong redirects (Expected %v, got %v)", expected, mercury.Redirects)
+	}
+	if venus := pages["Q309"]; venus == nil || len(venus.Redirects) != 0 {
+		t.Errorf("Page without label should have no redirects: %v", venus)
+	}
+
+	// Resources whose titles collided keep the redirects from the titles made
+	// from their URIs, and from the shared title
+	pages = map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, `
+<http://example.org/bob> <http://www.w3.org/2000/01/rdf-schema#label> "Bob" .
+<http://example.org/bob2> <http://www.w3.org/2000/01/rdf-schema#label> "Bob" .
+`, 1, false) {
+		pages[page.Title] = page
+	}
+	if bob2 := pages["Bob (bob2)"]; bob2 == nil || strings.Join(bob2.Redirects, "|") != "Bob|Bob2" {
+		t.Errorf("Wrong redirects for resource whose title collided: %v", bob2)
+	}
+
+	conf = DefaultConfig()
+	conf.Redirects.Enabled = false
+	for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+		if len(page.Redirects) > 0 {
+			t.Errorf("Redirects added though disabled: %v", page.Redirects)
+		}
+	}
+}
+
+// TestTripleAggregateToWikiPageConverterCategoryPages tests that classes get
+// category pages with their superclasses as categories and a description,
+// also when typed only by rdfs:subClassOf
+func TestTripleAggregateToWikiPageConverterCategoryPages(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/Agent> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Class> .
+<http://example.org/Person> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Class> .
+<http://example.org/Person> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example.org/Agent> .
+<http://example.org/Person> <http://www.w3.org/2000/01/rdf-schema#comment> "Eine Person"@de .
+<http://example.org/Person> <http://www.w3.org/2000/01/rdf-schema#comment> "A person"@en .
+<http://example.org/Student> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example.org/Person> .
+<http://example.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Student> .
+`
+	pages := map[string]*WikiPage{}
+	for _, page := range convertTestTriples(t, testData, 1, false) {
+		pages[page.Title] = page
+	}
+
+	person := pages["Category:Person"]
+	if person == nil || person.Type != URITypeClass {
+		t.Fatalf("Category page for Person missing: %v", pages)
+	}
+	if len(person.Categories) != 1 || person.Categories[0].Name != "Agent" {
+		t.Errorf("Wrong parent categories of Person: %v", person.Categories)
+	}
+	if person.Description != "A person" {
+		t.Errorf("Wrong description of Person: %q", person.Description)
+	}
+	for _, fact := range person.Facts {
+		if fact.Value == "A person" {
+			t.Errorf("Description of Person also added as fact: %v", fact)
+		}
+	}
+	if pages["Category:Agent"] == nil || len(pages["Category:Agent"].Categories) != 0 {
+		t.Errorf("Class without superclasses should have no categories: %v", pages["Category:Agent"])
+	}
+	student := pages["Category:Student"]
+	if student == nil || len(student.Categories) != 1 || student.Categories[0].Name != "Person" {
+		t.Errorf("Subclass without class type should get a category page: %v", student)
+	}
+	if alice := pages["Alice"]; alice == nil || alice.SpecificCategory == nil || alice.SpecificCategory.Name != "Student" {
+		t.Errorf("Instance should be in the category of its class: %v", alice)
+	}
+
+	conf := DefaultConfig()
+	conf.CategoryPages.Enabled = false
+	for _, page := range convertTestTriplesWithConfig(t, testData, conf) {
+		if page.Description != "" {
+			t.Errorf("Description added though category pages are disabled: %v", page)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	str "strings"
+)
+
+// Policies for choosing a single SMW type for a property that has values of
+// several types
+const (
+	// TypePolicyMajority picks the type of most of the values
+	TypePolicyMajority = "majority"
+	// TypePolicyWidest picks a type that can hold all of the values, which is
+	// Text whenever the values have different types
+	TypePolicyWidest = "widest"
+)
+
+var typePolicies = []string{TypePolicyMajority, TypePolicyWidest}
+
+const (
+	rdfsLiteralURI     = "http://www.w3.org/2000/01/rdf-schema#Literal"
+	rdfPlainLiteralURI = "http://www.w3.org/1999/02/22-rdf-syntax-ns#PlainLiteral"
+)
+
+// TypeConflict describes a property that has values of several SMW types, or
+// values of another type than given by its rdfs:range, and the type chosen
+// for it.
+type TypeConflict struct {
+	// Property is the title of the property page
+	Property string
+	// Counts holds the number of values seen per SMW type
+	Counts map[string]int
+	// Chosen is the SMW type chosen for the property
+	Chosen string
+	// Reason tells how the type was chosen: a type policy, or "rdfs:range"
+	Reason string
+}
+
+func (c *TypeConflict) String() string {
+	counts := []string{}
+	for _, smwType := range sortedTypes(c.Counts) {
+		counts = append(counts, fmt.Sprintf("%s: %d", smwType, c.Counts[smwType]))
+	}
+	return fmt.Sprintf("%s has values of type %s, using %s (%s)", c.Property, str.Join(counts, ", "), c.Chosen, c.Reason)
+}
+
+// typeTally counts the SMW types of the values seen per property page title.
+type typeTally map[string]map[string]int
+
+func (t typeTally) add(property string, smwType string) {
+	if t[property] == nil {
+		t[property] = make(map[string]int)
+	}
+	t[property][smwType]++
+}
+
+func (t typeTally) merge(other typeTally) {
+	for property, counts := range other {
+		for smwType, cnt := range counts {
+			if t[property] == nil {
+				t[property] = make(map[string]int)
+			}
+			t[property][smwType] += cnt
+		}
+	}
+}
+
+// chooseType picks a single SMW type for a property, from the counts of the
+// types of its values, and the type given by its rdfs:range, if any (which
+// always wins). It returns the type, and a *TypeConflict if the values did
+// not all agree with the chosen type, or "" if there is nothing to base the
+// type on.
+func chooseType(property string, counts map[string]int, rangeType string, policy string) (string, *TypeConflict) {
+	if rangeType != "" {
+		if len(counts) > 1 || (len(counts) == 1 && counts[rangeType] == 0) {
+			return rangeType, &TypeConflict{property, counts, rangeType, "rdfs:range"}
+		}
+		return rangeType, nil
+	}
+	types := sortedTypes(counts)
+	switch len(types) {
+	case 0:
+		return "", nil
+	case 1:
+		return types[0], nil
+	}
+	chosen := smwTypeText
+	if policy == TypePolicyMajority {
+		chosen = types[0]
+		for _, smwType := range types[1:] {
+			if counts[smwType] > counts[chosen] {
+				chosen = smwType
+			}
+		}
+	}
+	return chosen, &TypeConflict{property, counts, chosen, policy}
+}
+
+// rangeURIToSMWType returns the SMW type for a property with the range
+// rangeURI. Datatype ranges are mapped via dataTypes, rdfs:Literal and
+// unknown XSD datatypes to Text, and other (class) ranges to Page.
+func rangeURIToSMWType(rangeURI string, dataTypes map[string]string) string {
+	if smwType, ok := dataTypes[rangeURI]; ok {
+		return smwType
+	}
+	if rangeURI == rdfsLiteralURI || rangeURI == rdfPlainLiteralURI || str.HasPrefix(rangeURI, xsdNS) {
+		return smwTypeText
+	}
+	return smwTypePage
+}
+
+func sortedTypes(counts map[string]int) []string {
+	types := []string{}
+	for smwType := range counts {
+		types = append(types, smwType)
+	}
+	sort.Strings(types)
+	return types
+}
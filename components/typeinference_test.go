@@ -0,0 +1,105 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flowbase/flowbase"
+	"github.com/knakk/rdf"
+)
+
+func TestChooseType(t *testing.T) {
+	tests := []struct {
+		counts         map[string]int
+		rangeType      string
+		policy         string
+		expectedType   string
+		expectConflict bool
+	}{
+		{map[string]int{}, "", TypePolicyMajority, "", false},
+		{map[string]int{"Number": 3}, "", TypePolicyMajority, "Number", false},
+		{map[string]int{"Number": 3, "Page": 1}, "", TypePolicyMajority, "Number", true},
+		{map[string]int{"Number": 1, "Page": 1}, "", TypePolicyMajority, "Number", true},
+		{map[string]int{"Number": 3, "Page": 1}, "", TypePolicyWidest, "Text", true},
+		{map[string]int{"Date": 2}, "", TypePolicyWidest, "Date", false},
+		{map[string]int{"Number": 3, "Page": 1}, "Page", TypePolicyMajority, "Page", true},
+		{map[string]int{"Text": 2}, "Number", TypePolicyMajority, "Number", true},
+		{map[string]int{"Number": 2}, "Number", TypePolicyMajority, "Number", false},
+		{map[string]int{}, "Date", TypePolicyMajority, "Date", false},
+	}
+	for i, tt := range tests {
+		smwType, conflict := chooseType("Property:P", tt.counts, tt.rangeType, tt.policy)
+		if smwType != tt.expectedType {
+			t.Errorf("Test %d: wrong type (Expected %q, got %q)", i, tt.expectedType, smwType)
+		}
+		if (conflict != nil) != tt.expectConflict {
+			t.Errorf("Test %d: expected conflict: %v, got: %v", i, tt.expectConflict, conflict)
+		}
+	}
+}
+
+func TestTypeConflictString(t *testing.T) {
+	conflict := &TypeConflict{"Property:P", map[string]int{"Page": 1, "Number": 3}, "Number", TypePolicyMajority}
+	expected := "Property:P has values of type Number: 3, Page: 1, using Number (majority)"
+	if conflict.String() != expected {
+		t.Errorf("Wrong string for conflict (Expected %q, got %q)", expected, conflict.String())
+	}
+}
+
+func TestRangeURIToSMWType(t *testing.T) {
+	expected := map[string]string{
+		"http://www.w3.org/2001/XMLSchema#decimal":        "Number",
+		"http://www.w3.org/2001/XMLSchema#hexBinary":      "Text",
+		"http://www.w3.org/2000/01/rdf-schema#Literal":    "Text",
+		"http://example.org/Person":                       "Page",
+		"http://www.opengis.net/ont/geosparql#wktLiteral": "Geographic coordinate",
+	}
+	for rangeURI, smwType := range expected {
+		if got := rangeURIToSMWType(rangeURI, defaultDataTypes()); got != smwType {
+			t.Errorf("Wrong type for range %s (Expected %q, got %q)", rangeURI, smwType, got)
+		}
+	}
+}
+
+func TestTripleAggregateToWikiPageConverterTypeConflicts(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	testData := `
+<http://example.org/a> <http://example.org/rel> <http://example.org/b> .
+<http://example.org/a> <http://example.org/rel> "5"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/c> <http://example.org/rel> "6"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/c> <http://example.org/name> "C" .
+`
+	conv := NewTripleAggregateToWikiPageConverter(DefaultConfig())
+	conv.InIndex <- NewMemResourceIndex()
+	triples, err := rdf.NewTripleDecoder(strings.NewReader(testData), rdf.NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal("Could not decode n-triples test data: ", err.Error())
+	}
+	go func() {
+		defer close(conv.InAggregate)
+		conv.InAggregate <- NewTripleAggregate(triples[0].Subj, triples[0:2])
+		conv.InAggregate <- NewTripleAggregate(triples[2].Subj, triples[2:4])
+	}()
+	go conv.Run()
+
+	hasTypes := map[string][]string{}
+	for page := range conv.OutPage {
+		for _, fact := range page.Facts {
+			if fact.Property == "Has type" {
+				hasTypes[page.Title] = append(hasTypes[page.Title], fact.Value)
+			}
+		}
+	}
+
+	if len(hasTypes["Property:Rel"]) != 1 || hasTypes["Property:Rel"][0] != "Number" {
+		t.Errorf("Expected exactly one Has type Number on Property:Rel, got: %v", hasTypes["Property:Rel"])
+	}
+	if len(hasTypes["Property:Name"]) != 1 || hasTypes["Property:Name"][0] != "Text" {
+		t.Errorf("Expected exactly one Has type Text on Property:Name, got: %v", hasTypes["Property:Name"])
+	}
+	conflicts := conv.TypeConflicts()
+	if len(conflicts) != 1 || conflicts[0].Property != "Property:Rel" || conflicts[0].Counts["Page"] != 1 || conflicts[0].Counts["Number"] != 2 {
+		t.Errorf("Expected one type conflict for Property:Rel, got: %v", conflicts)
+	}
+}
@@ -20,7 +20,8 @@ func (p *WikiPagePrinter) Run() {
 	for page := range p.In {
 		fmt.Println("Title:", page.Title)
 		for _, fact := range page.Facts {
-			fmt.Print(fact.asWikiFact())
+			// Values of all types are escaped as entities, the default
+			fmt.Print(fact.asWikiFact(nil))
 		}
 		for _, cat := range page.Categories {
 			fmt.Print(cat.asWikiString())
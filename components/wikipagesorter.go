@@ -0,0 +1,62 @@
+package components
+
+import "sort"
+
+// WikiPageSorter is a process that collects all *WikiPage's it receives, and
+// sends them on in a reproducible order, sorted by namespace and then by
+// title, once the In port is closed. The facts of each page are sorted as
+// well, by property and then by value, so that the same input always gives
+// the same output. Since all pages are kept in memory until the end, it
+// should only be used when reproducible output is needed.
+type WikiPageSorter struct {
+	In  chan *WikiPage
+	Out chan *WikiPage
+}
+
+// NewWikiPageSorter returns an initialized WikiPageSorter process.
+func NewWikiPageSorter() *WikiPageSorter {
+	return &WikiPageSorter{
+		In:  make(chan *WikiPage, BUFSIZE),
+		Out: make(chan *WikiPage, BUFSIZE),
+	}
+}
+
+// Run runs the WikiPageSorter process.
+func (p *WikiPageSorter) Run() {
+	defer close(p.Out)
+
+	pages := []*WikiPage{}
+	for page := range p.In {
+		sortFacts(page.Facts)
+		for _, subobject := range page.Subobjects {
+			sortFacts(subobject.Facts)
+		}
+		pages = append(pages, page)
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		nsI, nsJ := pageTypeToMWNamespace[pages[i].Type], pageTypeToMWNamespace[pages[j].Type]
+		if nsI != nsJ {
+			return nsI < nsJ
+		}
+		return pages[i].Title < pages[j].Title
+	})
+
+	for _, page := range pages {
+		p.Out <- page
+	}
+}
+
+// sortFacts sorts facts by property, and then by value, except for values
+// of ordered lists, which keep their order.
+func sortFacts(facts []*Fact) {
+	sort.SliceStable(facts, func(i, j int) bool {
+		if facts[i].Property != facts[j].Property {
+			return facts[i].Property < facts[j].Property
+		}
+		if facts[i].ListIndex != facts[j].ListIndex {
+			return facts[i].ListIndex < facts[j].ListIndex
+		}
+		return facts[i].Value < facts[j].Value
+	})
+}
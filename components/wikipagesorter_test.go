@@ -0,0 +1,61 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/flowbase/flowbase"
+)
+
+func TestWikiPageSorter(t *testing.T) {
+	flowbase.InitLogWarning()
+
+	sorter := NewWikiPageSorter()
+	authors := []*Fact{NewFact("Has author", "Zed"), NewFact("Has author", "Adam")}
+	for i, fact := range authors {
+		fact.ListIndex = i + 1
+	}
+	go func() {
+		defer close(sorter.In)
+		sorter.In <- NewWikiPage("Property:Has name", []*Fact{}, []*Category{}, nil, URITypePredicate)
+		sorter.In <- NewWikiPage("Category:Person", []*Fact{}, []*Category{}, nil, URITypeClass)
+		sorter.In <- NewWikiPage("Bob", []*Fact{
+			NewFact("Has name", "Bob"),
+			NewFact("Equivalent URI", "http://example.org/bob"),
+			NewFact("Has friend", "Carol"),
+			NewFact("Has friend", "Alice"),
+			authors[0],
+			authors[1],
+		}, []*Category{}, nil, URITypeUndefined)
+		sorter.In <- NewWikiPage("Alice", []*Fact{}, []*Category{}, nil, URITypeUndefined)
+	}()
+	go sorter.Run()
+
+	pages := []*WikiPage{}
+	for page := range sorter.Out {
+		pages = append(pages, page)
+	}
+
+	expectedTitles := []string{"Alice", "Bob", "Category:Person", "Property:Has name"}
+	if len(pages) != len(expectedTitles) {
+		t.Fatalf("Wrong number of pages (Expected %d, got %d)", len(expectedTitles), len(pages))
+	}
+	for i, title := range expectedTitles {
+		if pages[i].Title != title {
+			t.Errorf("Wrong page at position %d (Expected %s, got %s)", i, title, pages[i].Title)
+		}
+	}
+
+	expectedFacts := []*Fact{
+		NewFact("Equivalent URI", "http://example.org/bob"),
+		authors[0],
+		authors[1],
+		NewFact("Has friend", "Alice"),
+		NewFact("Has friend", "Carol"),
+		NewFact("Has name", "Bob"),
+	}
+	for i, fact := range expectedFacts {
+		if *pages[1].Facts[i] != *fact {
+			t.Errorf("Wrong fact at position %d (Expected %v, got %v)", i, fact, pages[1].Facts[i])
+		}
+	}
+}
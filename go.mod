@@ -7,10 +7,16 @@ toolchain go1.24.0
 require (
 	github.com/flowbase/flowbase v0.1.0
 	github.com/knakk/rdf v0.0.0-20190304171630-8521bf4c5042
+	github.com/piprate/json-gold v0.7.0
 	github.com/spf13/afero v1.14.0
+	github.com/ulikunitz/xz v0.5.17
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/text v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/pquerna/cachecontrol v0.0.0-20180517163645-1555304b9b35 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
@@ -4,16 +4,103 @@ files, for import using MediaWiki's built in importDump.php script.
 
 Usage
 
-	./rdf2smw -in <infile> -out <outfile>
+	./rdf2smw -in <infile> [-in <infile> ...] -out <outfile> [-informat <format>]
+	          [-templates-out <file> -properties-out <file>] [-config <configfile>]
+	          [-on-error fail|skip|log] [-index memory|disk [-index-dir <dir>]]
+	          [-streaming | -two-pass] [-workers <n> [-keep-order]]
+	          [-deterministic] [-timestamp <time>] [-collision-report <file>]
+	          [-max-pages-per-file <n>] [-max-bytes-per-file <n>]
 
 Flags
 
-	-in  Input file in RDF N-triples format
-	-out Output file in (MediaWiki) XML format
+	-in       Input file in RDF format (N-Triples, Turtle, RDF/XML, N-Quads,
+	          TriG or JSON-LD), optionally compressed with gzip, bzip2 or xz.
+	          Can be given multiple times, and can be a comma separated list,
+	          a glob pattern or a directory (searched recursively). All input
+	          files are merged into one set of wiki pages. Use "-" to read
+	          from standard input.
+	-out      Output file in (MediaWiki) XML format. If ending in .gz or .xz,
+	          the output files are compressed accordingly. Use "-" to write
+	          to standard output.
+	-templates-out
+	          Output file for template pages (optional, by default named as
+	          the -out file with _templates added before .xml)
+	-properties-out
+	          Output file for property pages (optional, by default named as
+	          the -out file with _properties added before .xml)
+	-informat Format of the input file: turtle, ntriples, rdfxml, nquads, trig
+	          or jsonld (optional, detected from the file extension if not given)
+	-config   Mapping configuration file in JSON format, or in YAML format if
+	          ending in .yaml or .yml (optional)
+	-on-error What to do with input that can not be read: fail (stop at the
+	          first error, the default), skip (skip it, and print a summary
+	          at the end) or log (as skip, but also print each error)
+	-index    Where to keep the index of all triples during conversion: memory
+	          (the default, fastest) or disk (for datasets that do not fit in
+	          memory). Not used with -streaming.
+	-index-dir
+	          Directory for the on-disk index file (optional, defaults to the
+	          system temp directory)
+	-streaming
+	          The input is sorted (grouped) by subject: convert each
+	          resource as soon as all its triples are read, without
+	          indexing all triples first. Titles and types of referenced
+	          resources are then not looked up, so links use the local
+	          part of the URI as title.
+	-two-pass As -streaming, but read the input twice: first to index only
+	          the titles and types of all resources, and then to convert
+	          the resources, streaming. Memory use is then bounded by the
+	          size of the title and type index, rather than the whole
+	          graph. Can not be used when reading from standard input.
+	-workers  Number of resources to convert to wiki pages in parallel
+	          (optional, defaults to 1)
+	-keep-order
+	          With -workers, write the pages in the same order as with one
+	          worker, at the cost of some speed
+	-deterministic
+	          Write reproducible output: pages sorted by namespace and then
+	          title, facts sorted by property and value, and all revisions
+	          stamped with the same time, from -timestamp or the
+	          SOURCE_DATE_EPOCH environment variable (one of which is
+	          required). All pages are kept in memory until the end.
+	-timestamp
+	          Revision timestamp to use for all pages, in RFC 3339 format
+	          (e.g. 2024-01-31T12:00:00Z) or as seconds since the Unix
+	          epoch (optional, defaults to SOURCE_DATE_EPOCH if set, or
+	          else the current time)
+	-collision-report
+	          File to write the titles shared by several resources to, as
+	          tab-separated lines of the title, the URI of a resource and
+	          the title given to it (optional)
+	-max-pages-per-file
+	          Split the output into files of at most this many pages each
+	          (optional, 0 for no limit, the default)
+	-max-bytes-per-file
+	          Split the output into files of at most this many bytes each,
+	          before compression (optional, 0 for no limit, the default).
+	          A page larger than this still gets a file of its own.
+
+If any input was skipped due to errors, rdf2smw exits with status 2.
+
+When writing to standard output, template and property pages are included in
+the same XML document as the other pages, unless -templates-out and
+-properties-out are given.
+
+With -max-pages-per-file or -max-bytes-per-file, each output file is split into
+numbered files, each a complete XML document, as in mydata_0001.xml,
+mydata_0002.xml and so on for mydata.xml. Category pages are then written to
+files of their own (named as the -out file with _categories added before
+.xml), and a manifest listing all files written, in the order they
+should be imported (templates, properties, categories and then other pages), is
+written to a file named as the -out file with .xml replaced by _manifest.txt.
+Splitting can not be used when writing to standard output.
 
 Example usage
 
 	./rdf2smw -in mydata.nt -out mydata.xml
+	./rdf2smw -in ontology.owl -in 'data/*.nt' -out mydata.xml
+	./rdf2smw -in mydata.nt -out mydata.xml.gz -max-pages-per-file 100000
+	curl -s https://example.org/mydata.nt | ./rdf2smw -in - -out - | php importDump.php
 
 For importing the generated XML Dumps into MediaWiki, see this page:
 https://www.mediawiki.org/wiki/Manual:Importing_XML_dumps
@@ -24,11 +111,15 @@ import (
 	"flag"
 	"fmt"
 	"github.com/rdfio/rdf2smw/components"
+	"io"
 	"os"
+	"strconv"
+	"time"
 
 	str "strings"
 
 	"github.com/flowbase/flowbase"
+	"github.com/spf13/afero"
 )
 
 const (
@@ -38,12 +129,29 @@ const (
 func main() {
 	//flowbase.InitLogDebug()
 
-	inFileName := flag.String("in", "", "The input file name")
-	outFileName := flag.String("out", "", "The output file name")
+	inPaths := stringList{}
+	flag.Var(&inPaths, "in", "The input file name (can be given multiple times, and be a comma separated list, a glob pattern or a directory)")
+	outFileName := flag.String("out", "", "The output file name (\"-\" for standard output)")
+	templatesOutFileName := flag.String("templates-out", "", "The output file name for template pages (optional)")
+	propertiesOutFileName := flag.String("properties-out", "", "The output file name for property pages (optional)")
+	inFormat := flag.String("informat", "", "The input format: turtle, ntriples, rdfxml, nquads, trig or jsonld (default: detected from file extension)")
+	configFileName := flag.String("config", "", "A mapping configuration file in JSON format, or YAML if ending in .yaml or .yml (optional)")
+	indexBackend := flag.String("index", components.IndexBackendMemory, "Where to keep the index of all triples: memory or disk")
+	indexDir := flag.String("index-dir", "", "Directory for the on-disk index, with -index disk (default: the system temp directory)")
+	streaming := flag.Bool("streaming", false, "The input is sorted by subject: convert resources as they are read, without indexing all triples")
+	twoPass := flag.Bool("two-pass", false, "As -streaming, but read the input twice, first indexing only titles and types of all resources")
+	workers := flag.Int("workers", 1, "Number of resources to convert to wiki pages in parallel")
+	keepOrder := flag.Bool("keep-order", false, "With -workers, write pages in the same order as with one worker")
+	deterministic := flag.Bool("deterministic", false, "Write reproducible output, with sorted pages and facts, and a fixed timestamp")
+	timestampStr := flag.String("timestamp", "", "Revision timestamp for all pages, in RFC 3339 format or as seconds since the Unix epoch (default: SOURCE_DATE_EPOCH, or the current time)")
+	collisionReportFileName := flag.String("collision-report", "", "File to write the titles shared by several resources to (optional)")
+	onError := flag.String("on-error", components.ErrorPolicyFail, "What to do with input that can not be read: fail, skip or log")
+	maxPagesPerFile := flag.Int("max-pages-per-file", 0, "Split the output into files of at most this many pages each (0 for no limit)")
+	maxBytesPerFile := flag.Int("max-bytes-per-file", 0, "Split the output into files of at most this many bytes each, before compression (0 for no limit)")
 	flag.Parse()
 
 	doExit := false
-	if *inFileName == "" {
+	if len(inPaths) == 0 {
 		fmt.Println("No filename specified to --in")
 		doExit = true
 	} else if *outFileName == "" {
@@ -51,10 +159,128 @@ func main() {
 		doExit = true
 	}
 
+	for _, fileName := range []string{*outFileName, *templatesOutFileName, *propertiesOutFileName} {
+		if err := components.CheckWritableCompression(fileName); err != nil {
+			fmt.Println("Invalid output filename specified:", err.Error())
+			doExit = true
+		}
+	}
+
+	if *maxPagesPerFile < 0 || *maxBytesPerFile < 0 {
+		fmt.Println("The --max-pages-per-file and --max-bytes-per-file limits can not be negative")
+		doExit = true
+	}
+	chunked := *maxPagesPerFile > 0 || *maxBytesPerFile > 0
+	for _, fileName := range []string{*outFileName, *templatesOutFileName, *propertiesOutFileName} {
+		if chunked && fileName == components.StdioFileName {
+			fmt.Println("The output can not be split into files with --max-pages-per-file or --max-bytes-per-file when writing to standard output")
+			doExit = true
+			break
+		}
+	}
+
+	interleave := false
+	categoriesOutFileName := ""
+	manifestFileName := ""
+	if *outFileName == components.StdioFileName {
+		if *templatesOutFileName == "" && *propertiesOutFileName == "" {
+			interleave = true
+		} else if *templatesOutFileName == "" || *propertiesOutFileName == "" {
+			fmt.Println("Either both or none of --templates-out and --properties-out have to be specified when writing to standard output")
+			doExit = true
+		}
+	} else {
+		if *templatesOutFileName == "" {
+			*templatesOutFileName = sideFileName(*outFileName, "templates")
+		}
+		if *propertiesOutFileName == "" {
+			*propertiesOutFileName = sideFileName(*outFileName, "properties")
+		}
+		categoriesOutFileName = sideFileName(*outFileName, "categories")
+		manifestFileName = str.TrimSuffix(components.TrimCompressionExt(*outFileName), ".xml") + "_manifest.txt"
+	}
+
+	if *inFormat != "" {
+		if _, err := components.ParseRDFFormat(*inFormat); err != nil {
+			fmt.Println("Invalid format specified to --informat:", err.Error())
+			doExit = true
+		}
+	}
+
+	if err := components.CheckErrorPolicy(*onError); err != nil {
+		fmt.Println("Invalid value specified to --on-error:", err.Error())
+		doExit = true
+	}
+
+	if *twoPass {
+		for _, inPath := range inPaths {
+			if inPath == components.StdioFileName {
+				fmt.Println("Can not read standard input twice, as needed by --two-pass")
+				doExit = true
+			}
+		}
+		if *streaming {
+			fmt.Println("Only one of --streaming and --two-pass can be specified")
+			doExit = true
+		}
+	}
+
+	if *streaming && (*indexBackend != components.IndexBackendMemory || *indexDir != "") {
+		fmt.Println("--index and --index-dir can not be used with --streaming, which does not index the triples")
+		doExit = true
+	}
+
+	if *workers < 1 {
+		fmt.Println("The number of --workers has to be at least 1")
+		doExit = true
+	}
+
+	if *timestampStr == "" {
+		*timestampStr = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	var timestamp time.Time
+	if *timestampStr != "" {
+		var err error
+		timestamp, err = parseTimestamp(*timestampStr)
+		if err != nil {
+			fmt.Println("Invalid timestamp specified to --timestamp or SOURCE_DATE_EPOCH:", err.Error())
+			doExit = true
+		}
+	} else if *deterministic {
+		fmt.Println("A fixed timestamp is needed for --deterministic: specify --timestamp, or set SOURCE_DATE_EPOCH")
+		doExit = true
+	}
+
 	if doExit {
 		os.Exit(1)
 	}
 
+	if *outFileName == components.StdioFileName {
+		// Keep standard output clean for the XML, by logging to stderr only
+		flowbase.InitLog(io.Discard, io.Discard, io.Discard, os.Stderr, os.Stderr, os.Stderr)
+	}
+
+	inFileNames, err := components.ExpandInputFileNames(afero.NewOsFs(), inPaths)
+	if err != nil {
+		fmt.Println("Could not find input files:", err.Error())
+		os.Exit(1)
+	}
+
+	conf := components.DefaultConfig()
+	if *configFileName != "" {
+		conf, err = components.LoadConfig(*configFileName)
+		if err != nil {
+			fmt.Println("Could not load config:", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	resourceIndex, err := components.NewResourceIndex(*indexBackend, *indexDir)
+	if err != nil {
+		fmt.Println("Could not create resource index:", err.Error())
+		os.Exit(1)
+	}
+
 	// ------------------------------------------
 	// Initialize processes
 	// ------------------------------------------
@@ -63,27 +289,53 @@ func main() {
 	net := flowbase.NewNet()
 
 	// Read in-file
-	ttlFileRead := components.NewOsTurtleFileReader()
-	net.AddProcess(ttlFileRead)
-
-	// TripleAggregator
-	aggregator := components.NewTripleAggregator()
-	net.AddProcess(aggregator)
-
-	// Create an subject-indexed "index" of all triples
-	indexCreator := components.NewResourceIndexCreator()
-	net.AddProcess(indexCreator)
-
-	// Fan-out the triple index to the converter and serializer
-	indexFanOut := components.NewResourceIndexFanOut()
-	net.AddProcess(indexFanOut)
-
-	// Serialize the index back to individual subject-tripleaggregates
-	indexToAggr := components.NewResourceIndexToTripleAggregates()
-	net.AddProcess(indexToAggr)
+	rdfFileRead := components.NewOsRDFFileReader()
+	rdfFileRead.Format = *inFormat
+	net.AddProcess(rdfFileRead)
+
+	var indexCreator *components.ResourceIndexCreator
+	var indexFanOut *components.ResourceIndexFanOut
+	var indexToAggr *components.ResourceIndexToTripleAggregates
+	var streamRdfFileRead *components.RDFFileReader
+	var streamAggregator *components.StreamingTripleAggregator
+	if !*streaming {
+		// Create an subject-indexed "index" of all triples (or in two-pass
+		// mode, only of the triples needed to look up titles and types)
+		indexCreator = components.NewResourceIndexCreator(resourceIndex)
+		if *twoPass {
+			indexCreator.Predicates = conf.LookupPredicates()
+		}
+		net.AddProcess(indexCreator)
+	}
+	if *streaming || *twoPass {
+		// Read in-file (again, in two-pass mode), for the resources to convert
+		streamRdfFileRead = rdfFileRead
+		if *twoPass {
+			streamRdfFileRead = components.NewOsRDFFileReader()
+			streamRdfFileRead.Format = *inFormat
+			net.AddProcess(streamRdfFileRead)
+		}
+
+		// Aggregate triples per subject, as they are read
+		streamAggregator = components.NewStreamingTripleAggregator()
+		net.AddProcess(streamAggregator)
+	} else {
+		// Fan-out the triple index to the converter and serializer
+		indexFanOut = components.NewResourceIndexFanOut()
+		net.AddProcess(indexFanOut)
+
+		// Serialize the index back to individual subject-tripleaggregates
+		indexToAggr = components.NewResourceIndexToTripleAggregates()
+		net.AddProcess(indexToAggr)
+	}
 
 	// Convert TripleAggregate to WikiPage
-	triplesToWikiConverter := components.NewTripleAggregateToWikiPageConverter()
+	triplesToWikiConverter := components.NewTripleAggregateToWikiPageConverter(conf)
+	triplesToWikiConverter.Workers = *workers
+	triplesToWikiConverter.KeepOrder = *keepOrder || *deterministic
+	// Collect the namespace prefixes declared in the input, for abbreviated
+	// titles
+	rdfFileRead.Prefixes = triplesToWikiConverter.Prefixes
 	net.AddProcess(triplesToWikiConverter)
 
 	//categoryFilterer := components.NewCategoryFilterer([]string{"DataEntry"})
@@ -93,59 +345,244 @@ func main() {
 	//wikiPagePrinter := components.NewWikiPagePrinter()
 	//net.AddProcess(wikiPagePrinter)
 
-	useTemplates := true
-	xmlCreator := components.NewMWXMLCreator(useTemplates)
+	var pageSorter *components.WikiPageSorter
+	if *deterministic {
+		// Sort pages and facts, for reproducible output
+		pageSorter = components.NewWikiPageSorter()
+		net.AddProcess(pageSorter)
+	}
+
+	xmlCreator := components.NewMWXMLCreator(conf)
+	xmlCreator.Interleave = interleave
+	xmlCreator.Timestamp = timestamp
+	xmlCreator.SeparateCategories = chunked
 	net.AddProcess(xmlCreator)
 
 	//printer := components.NewStringPrinter()
 	//net.AddProcess(printer)
-	templateWriter := components.NewStringFileWriter(str.Replace(*outFileName, ".xml", "_templates.xml", 1))
-	net.AddProcess(templateWriter)
-
-	propertyWriter := components.NewStringFileWriter(str.Replace(*outFileName, ".xml", "_properties.xml", 1))
-	net.AddProcess(propertyWriter)
+	var templateWriter, propertyWriter, pageWriter *components.StringFileWriter
+	var chunkWriters []*components.ChunkedMWXMLWriter
+	if chunked {
+		// Split each document into files, in the order they are to be
+		// imported
+		for _, fileName := range []string{*templatesOutFileName, *propertiesOutFileName, categoriesOutFileName, *outFileName} {
+			chunkWriter := components.NewChunkedMWXMLWriter(fileName, *maxPagesPerFile, *maxBytesPerFile)
+			chunkWriters = append(chunkWriters, chunkWriter)
+			net.AddProcess(chunkWriter)
+		}
+	} else {
+		if !interleave {
+			templateWriter = components.NewStringFileWriter(*templatesOutFileName)
+			net.AddProcess(templateWriter)
+
+			propertyWriter = components.NewStringFileWriter(*propertiesOutFileName)
+			net.AddProcess(propertyWriter)
+		}
+
+		pageWriter = components.NewStringFileWriter(*outFileName)
+		net.AddProcess(pageWriter)
+	}
 
-	pageWriter := components.NewStringFileWriter(*outFileName)
-	net.AddProcess(pageWriter)
+	errCollector := components.NewErrorCollector(*onError)
+	net.AddProcess(errCollector)
 
-	snk := flowbase.NewSink()
+	snk := components.NewSink()
 	net.AddProcess(snk)
 
 	// ------------------------------------------
 	// Connect network
 	// ------------------------------------------
 
-	ttlFileRead.OutTriple = aggregator.In
-
-	aggregator.Out = indexCreator.In
-
-	indexCreator.Out = indexFanOut.In
-	indexFanOut.Out["serialize"] = indexToAggr.In
-	indexFanOut.Out["conv"] = triplesToWikiConverter.InIndex
-
-	indexToAggr.Out = triplesToWikiConverter.InAggregate
+	switch {
+	case *streaming:
+		rdfFileRead.OutTriple = streamAggregator.In
+		streamAggregator.Out = triplesToWikiConverter.InAggregate
+		// No titles or types are looked up, so use the (empty) index as is
+		triplesToWikiConverter.InIndex <- resourceIndex
+		close(triplesToWikiConverter.InIndex)
+	case *twoPass:
+		rdfFileRead.OutTriple = indexCreator.In
+		indexCreator.Out = triplesToWikiConverter.InIndex
+		streamRdfFileRead.OutTriple = streamAggregator.In
+		streamAggregator.Out = triplesToWikiConverter.InAggregate
+	default:
+		rdfFileRead.OutTriple = indexCreator.In
+
+		indexCreator.Out = indexFanOut.In
+		indexFanOut.Out["serialize"] = indexToAggr.In
+		indexFanOut.Out["conv"] = triplesToWikiConverter.InIndex
+
+		indexToAggr.Out = triplesToWikiConverter.InAggregate
+	}
 
 	//triplesToWikiConverter.OutPage = categoryFilterer.In
 	//categoryFilterer.Out = xmlCreator.InWikiPage
 
-	triplesToWikiConverter.OutPage = xmlCreator.InWikiPage
+	if pageSorter != nil {
+		triplesToWikiConverter.OutPage = pageSorter.In
+		pageSorter.Out = xmlCreator.InWikiPage
+	} else {
+		triplesToWikiConverter.OutPage = xmlCreator.InWikiPage
+	}
 
-	xmlCreator.OutTemplates = templateWriter.In
-	xmlCreator.OutProperties = propertyWriter.In
-	xmlCreator.OutPages = pageWriter.In
+	if chunked {
+		xmlCreator.OutTemplates = chunkWriters[0].In
+		xmlCreator.OutProperties = chunkWriters[1].In
+		xmlCreator.OutCategories = chunkWriters[2].In
+		xmlCreator.OutPages = chunkWriters[3].In
+		for _, chunkWriter := range chunkWriters {
+			errCollector.Connect(chunkWriter.OutError)
+			snk.Connect(chunkWriter.OutDone)
+		}
+	} else {
+		if !interleave {
+			xmlCreator.OutTemplates = templateWriter.In
+			xmlCreator.OutProperties = propertyWriter.In
+			errCollector.Connect(templateWriter.OutError)
+			errCollector.Connect(propertyWriter.OutError)
+			snk.Connect(templateWriter.OutDone)
+			snk.Connect(propertyWriter.OutDone)
+		}
+		xmlCreator.OutPages = pageWriter.In
+		errCollector.Connect(pageWriter.OutError)
+		snk.Connect(pageWriter.OutDone)
+	}
 
-	snk.Connect(templateWriter.OutDone)
-	snk.Connect(propertyWriter.OutDone)
-	snk.Connect(pageWriter.OutDone)
+	if *twoPass {
+		// Both passes see the same read errors, so only report those of the
+		// second pass
+		go func() {
+			for range rdfFileRead.OutError {
+			}
+		}()
+		errCollector.Connect(streamRdfFileRead.OutError)
+	} else {
+		errCollector.Connect(rdfFileRead.OutError)
+	}
+	if indexCreator != nil {
+		errCollector.Connect(indexCreator.OutError)
+	}
+	if streamAggregator != nil {
+		errCollector.Connect(streamAggregator.OutError)
+	}
+	errCollector.Connect(triplesToWikiConverter.OutError)
+	snk.Connect(errCollector.OutDone)
 
 	// ------------------------------------------
 	// Send in-data and run
 	// ------------------------------------------
 
-	go func() {
-		defer close(ttlFileRead.InFileName)
-		ttlFileRead.InFileName <- *inFileName
-	}()
+	fileReaders := []*components.RDFFileReader{rdfFileRead}
+	if *twoPass {
+		fileReaders = append(fileReaders, streamRdfFileRead)
+	}
+	for _, fileReader := range fileReaders {
+		fileReader.Stop = errCollector.Stop
+		go func(fileReader *components.RDFFileReader) {
+			defer close(fileReader.InFileName)
+			for _, inFileName := range inFileNames {
+				fileReader.InFileName <- inFileName
+			}
+		}(fileReader)
+	}
 
 	net.Run()
+
+	resourceIndex.Close()
+
+	if conflicts := triplesToWikiConverter.TypeConflicts(); len(conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "%d properties have values of several types (see typeConflictPolicy in the configuration):\n", len(conflicts))
+		for _, conflict := range conflicts {
+			fmt.Fprintln(os.Stderr, "\t"+conflict.String())
+		}
+	}
+
+	if collisions := triplesToWikiConverter.TitleCollisions(); len(collisions) > 0 {
+		fmt.Fprintf(os.Stderr, "%d titles are shared by several resources, and were made unique (see titleCollisionStrategy in the configuration)\n", len(collisions))
+	}
+	if *collisionReportFileName != "" {
+		if err := writeCollisionReport(*collisionReportFileName, triplesToWikiConverter.TitleCollisions()); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not write collision report:", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if chunked {
+		fileNames := []string{}
+		for _, chunkWriter := range chunkWriters {
+			fileNames = append(fileNames, chunkWriter.FileNames()...)
+		}
+		if err := writeManifest(manifestFileName, fileNames); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not write manifest:", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if errCollector.Failed() {
+		os.Exit(1)
+	}
+	if errCollector.ErrorCount() > 0 {
+		os.Exit(2)
+	}
+}
+
+// sideFileName returns the name of the file to write the pages of the kind
+// kind (such as "templates") to, next to the output file outFileName: its
+// name without the .xml extension (if any) and with "_<kind>.xml" added,
+// keeping the compression extension, as in pages_templates.xml.gz for
+// pages.xml.gz.
+func sideFileName(outFileName string, kind string) string {
+	uncompressed := components.TrimCompressionExt(outFileName)
+	return str.TrimSuffix(uncompressed, ".xml") + "_" + kind + ".xml" + str.TrimPrefix(outFileName, uncompressed)
+}
+
+// writeManifest writes the names of the output files to the file fileName,
+// one per line, in the order they should be imported.
+func writeManifest(fileName string, fileNames []string) error {
+	fh, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	for _, outFileName := range fileNames {
+		if _, err := fmt.Fprintln(fh, outFileName); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+	return fh.Close()
+}
+
+// writeCollisionReport writes the title collisions to the file fileName.
+func writeCollisionReport(fileName string, collisions []*components.TitleCollision) error {
+	fh, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := components.WriteTitleCollisionReport(fh, collisions); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// parseTimestamp parses a timestamp in RFC 3339 format, or given as seconds
+// since the Unix epoch (as in SOURCE_DATE_EPOCH).
+func parseTimestamp(timestampStr string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(timestampStr, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, timestampStr)
+}
+
+// stringList is a flag.Value collecting the values of a flag that is given
+// multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return str.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rdfio/rdf2smw/components"
+)
+
+// TestSideFileName tests that the files for templates, properties and
+// categories are named after the output file, also without an .xml extension
+func TestSideFileName(t *testing.T) {
+	tests := map[string]string{
+		"pages.xml":      "pages_templates.xml",
+		"pages.xml.gz":   "pages_templates.xml.gz",
+		"out/dump":       "out/dump_templates.xml",
+		"out/dump.bz2":   "out/dump_templates.xml.bz2",
+		"my.xml.d/pages": "my.xml.d/pages_templates.xml",
+	}
+	for outFileName, expected := range tests {
+		if fileName := sideFileName(outFileName, "templates"); fileName != expected {
+			t.Errorf("Wrong templates file name for %s (Expected %s, got %s)", outFileName, expected, fileName)
+		}
+	}
+}
+
+// TestSideFileNameChunks tests that the chunk files of the pages, templates,
+// properties and categories get distinct names
+func TestSideFileNameChunks(t *testing.T) {
+	for _, outFileName := range []string{"pages.xml", "pages.xml.gz", "out/dump", "out/dump.xz"} {
+		fileNames := []string{outFileName}
+		for _, kind := range []string{"templates", "properties", "categories"} {
+			fileNames = append(fileNames, sideFileName(outFileName, kind))
+		}
+		seen := make(map[string]bool)
+		for _, fileName := range fileNames {
+			chunkFileName := components.ChunkFileName(fileName, 1)
+			if seen[chunkFileName] {
+				t.Errorf("Chunk file name %s used twice with output file %s", chunkFileName, outFileName)
+			}
+			seen[chunkFileName] = true
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package mwtitle turns arbitrary strings into valid MediaWiki page titles,
+// following the rules MediaWiki applies to titles: the set of legal
+// characters, the length limit of 255 bytes, whitespace and underscores being
+// the same, and capitalisation of the first letter ($wgCapitalLinks). Titles
+// are also kept from being taken as namespace or interwiki prefixed titles,
+// or as subpages.
+package mwtitle
+
+import (
+	"regexp"
+	str "strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTitleBytes is the maximum length of a title (without namespace prefix)
+// in bytes, in UTF-8.
+const MaxTitleBytes = 255
+
+// DefaultNamespaces lists the names and aliases of the namespaces of a
+// MediaWiki with Semantic MediaWiki and Page Forms, which titles must not
+// start with (followed by a colon).
+var DefaultNamespaces = []string{
+	"Media", "Special", "Talk", "User", "User talk", "Project", "Project talk",
+	"File", "File talk", "Image", "Image talk", "MediaWiki", "MediaWiki talk",
+	"Template", "Template talk", "Help", "Help talk", "Category",
+	"Category talk", "Module", "Module talk", "Property", "Property talk",
+	"Concept", "Concept talk", "Form", "Form talk", "SMW", "SMW talk",
+}
+
+// DefaultInterwikiPrefixes lists the interwiki prefixes of a default
+// MediaWiki installation, which titles must not start with (followed by a
+// colon).
+var DefaultInterwikiPrefixes = []string{
+	"acronym", "advogato", "arxiv", "c2find", "cache", "commons", "dictionary",
+	"doi", "drumcorpswiki", "dwjwiki", "emacswiki", "foldoc", "foxwiki",
+	"freebsdman", "gentoo-wiki", "google", "googlegroups", "hammondwiki",
+	"hrwiki", "imdb", "kmwiki", "linuxwiki", "lojban", "lqwiki", "meatball",
+	"mediawikiwiki", "memoryalpha", "metawiki", "metawikimedia", "mozillawiki",
+	"mw", "mwod", "oeis", "openwiki", "pmid", "pythoninfo", "rfc", "s23wiki",
+	"seattlewireless", "senseislibrary", "shoutwiki", "squeak", "theopedia",
+	"tmbw", "tmnet", "twiki", "uncyclopedia", "unreal", "usemod", "wiki",
+	"wikia", "wikibooks", "wikidata", "wikif1", "wikihow", "wikimedia",
+	"wikinews", "wikinfo", "wikipedia", "wikiquote", "wikisource",
+	"wikispecies", "wikiversity", "wikivoyage", "wikt", "wiktionary",
+}
+
+// illegalChars maps the characters not allowed in titles to legal ones.
+var illegalChars = str.NewReplacer(
+	"[", "(",
+	"]", ")",
+	"{", "(",
+	"}", ")",
+	"<", "(",
+	">", ")",
+	"|", "-",
+	"#", " ",
+	"_", " ",
+	// Slashes would make subpages, in namespaces with subpages enabled
+	"/", "-",
+	"\ufffd", "",
+)
+
+var (
+	percentEncodingRegex = regexp.MustCompile(`%([0-9A-Fa-f]{2})`)
+	htmlEntityRegex      = regexp.MustCompile(`&(#?[0-9A-Za-z]+;)`)
+	tildesRegex          = regexp.MustCompile(`~{3,}`)
+)
+
+// Normalizer normalises strings into valid titles.
+type Normalizer struct {
+	// CapitalLinks makes the first letter of titles upper case, as with
+	// MediaWiki's $wgCapitalLinks (which is on by default)
+	CapitalLinks bool
+	reserved     map[string]bool
+}
+
+// NewNormalizer returns a Normalizer, keeping titles from starting with the
+// DefaultNamespaces, the DefaultInterwikiPrefixes, or the extra prefixes
+// reservedPrefixes (such as custom namespaces, or the project name).
+func NewNormalizer(capitalLinks bool, reservedPrefixes []string) *Normalizer {
+	n := &Normalizer{
+		CapitalLinks: capitalLinks,
+		reserved:     make(map[string]bool),
+	}
+	for _, prefixes := range [][]string{DefaultNamespaces, DefaultInterwikiPrefixes, reservedPrefixes} {
+		for _, prefix := range prefixes {
+			n.reserved[prefixKey(prefix)] = true
+		}
+	}
+	return n
+}
+
+// Normalize returns title as a valid title, with illegal characters replaced,
+// whitespace collapsed, and the first letter capitalised (if CapitalLinks is
+// set). A title starting with a reserved prefix and a colon gets the colon
+// replaced with " - ", and titles longer than MaxTitleBytes are shortened
+// with Truncate. Normalize returns "" if nothing is left of title.
+func (n *Normalizer) Normalize(title string) string {
+	title = norm.NFC.String(title)
+	title = str.Map(func(r rune) rune {
+		switch {
+		case r == '\u200e' || r == '\u200f' || (r >= '\u202a' && r <= '\u202e'):
+			// Direction marks are removed by MediaWiki
+			return -1
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, title)
+	title = illegalChars.Replace(title)
+	title = percentEncodingRegex.ReplaceAllString(title, "% $1")
+	title = htmlEntityRegex.ReplaceAllString(title, "& $1")
+	title = tildesRegex.ReplaceAllString(title, "~~")
+	title = str.Join(str.Fields(title), " ")
+	title = str.TrimSpace(str.TrimLeft(title, ":"))
+
+	if i := str.Index(title, ":"); i > 0 && n.reserved[prefixKey(title[:i])] {
+		title = str.TrimSpace(title[:i]) + " - " + str.TrimSpace(title[i+1:])
+	}
+	if title != "" && str.Trim(title, ".") == "" {
+		// "." and ".." are not valid titles
+		title = str.TrimSpace(str.Repeat("Dot ", len(title)))
+	}
+
+	if n.CapitalLinks && title != "" {
+		first, size := utf8.DecodeRuneInString(title)
+		title = string(unicode.ToUpper(first)) + title[size:]
+	}
+	return Truncate(title, MaxTitleBytes)
+}
+
+// Truncate shortens title to at most maxBytes bytes, if longer, by cutting
+// it at the last space that leaves room for an added " ...", or at the last
+// full character if there is no such space.
+func Truncate(title string, maxBytes int) string {
+	if len(title) <= maxBytes {
+		return title
+	}
+	const ellipsis = " ..."
+	cut := maxBytes - len(ellipsis)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(title[cut]) {
+		cut--
+	}
+	shortened := title[:cut]
+	if i := str.LastIndex(shortened, " "); i > 0 {
+		shortened = shortened[:i]
+	}
+	return str.TrimRight(shortened, " ") + ellipsis
+}
+
+// prefixKey returns the form of a namespace or interwiki prefix used for
+// comparing prefixes, which are case-insensitive and treat underscores and
+// spaces the same.
+func prefixKey(prefix string) string {
+	return str.ToLower(str.Join(str.Fields(str.Replace(prefix, "_", " ", -1)), " "))
+}
@@ -0,0 +1,77 @@
+package mwtitle
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalize(t *testing.T) {
+	n := NewNormalizer(true, []string{"My Wiki"})
+
+	tests := map[string]string{
+		"simple":                   "Simple",
+		"under_scores  and\tspace": "Under scores and space",
+		"  padded ":                "Padded",
+		"a[b]{c}<d>|e#f":           "A(b)(c)(d)-e f",
+		"AC/DC":                    "AC-DC",
+		"50%25 off":                "50% 25 off",
+		"Tom &amp; Jerry":          "Tom & amp; Jerry",
+		"Sign ~~~~":                "Sign ~~",
+		"::leading colon":          "Leading colon",
+		"Talk:Foo":                 "Talk - Foo",
+		"user_talk: Foo":           "User talk - Foo",
+		"Wikipedia:Foo":            "Wikipedia - Foo",
+		"my_wiki:Foo":              "My wiki - Foo",
+		"dc:title":                 "Dc:title",
+		"Star Trek: Voyager":       "Star Trek: Voyager",
+		"St. Louis":                "St. Louis",
+		".":                        "Dot",
+		"..":                       "Dot Dot",
+		"élan":                     "Élan",
+		"ßtraße":                   "ßtraße",
+		"e\u0301":                  "\u00c9",
+		"left\u200eto\u200fright":  "Lefttoright",
+		"new\nline":                "New line",
+		"":                         "",
+		" _ ":                      "",
+	}
+	for title, expected := range tests {
+		if normalized := n.Normalize(title); normalized != expected {
+			t.Errorf("Wrong normalisation of %q (Expected %q, got %q)", title, expected, normalized)
+		}
+	}
+
+	n.CapitalLinks = false
+	if normalized := n.Normalize("iPhone"); normalized != "iPhone" {
+		t.Errorf("First letter capitalised without CapitalLinks: %s", normalized)
+	}
+}
+
+func TestNormalizeIsIdempotent(t *testing.T) {
+	n := NewNormalizer(true, nil)
+	for _, title := range []string{"Talk:Foo", "a_b  c", "50%25", "x/y", strings.Repeat("ö ", 200)} {
+		once := n.Normalize(title)
+		if twice := n.Normalize(once); twice != once {
+			t.Errorf("Normalising %q twice gives %q, not %q", title, twice, once)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("ö", 200)
+	truncated := Truncate(long, MaxTitleBytes)
+	if len(truncated) > MaxTitleBytes || !utf8.ValidString(truncated) || !strings.HasSuffix(truncated, " ...") {
+		t.Errorf("Wrong truncation of multibyte title: %q (%d bytes)", truncated, len(truncated))
+	}
+
+	words := strings.Repeat("word ", 60)
+	truncated = Truncate(words, MaxTitleBytes)
+	if len(truncated) > MaxTitleBytes || !strings.HasSuffix(truncated, "word ...") {
+		t.Errorf("Title not truncated at a word boundary: %q", truncated)
+	}
+
+	if Truncate("short", MaxTitleBytes) != "short" {
+		t.Error("Short title truncated")
+	}
+}